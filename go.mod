@@ -3,14 +3,24 @@ module repo
 go 1.24
 
 require (
+	code.gitea.io/sdk/gitea v0.23.2
+	github.com/google/go-github/v72 v72.0.0
 	github.com/knadh/koanf/providers/posflag v1.0.1
-	github.com/logrusorgru/aurora/v4 v4.0.0 // indirect
+	github.com/logrusorgru/aurora/v4 v4.0.0
 	github.com/slack-go/slack v0.9.1
 	github.com/spf13/cobra v1.1.3
 	github.com/xanzy/go-gitlab v0.115.0
 	gopkg.in/yaml.v2 v2.4.0
 )
 
+require (
+	github.com/42wim/httpsig v1.2.3 // indirect
+	github.com/davidmz/go-pageant v1.0.2 // indirect
+	github.com/go-fed/httpsig v1.1.0 // indirect
+	github.com/hashicorp/go-version v1.7.0 // indirect
+	golang.org/x/crypto v0.39.0 // indirect
+)
+
 require (
 	github.com/fatih/structs v1.1.0 // indirect
 	github.com/fsnotify/fsnotify v1.9.0 // indirect
@@ -31,7 +41,7 @@ require (
 	github.com/mitchellh/reflectwalk v1.0.2 // indirect
 	github.com/pkg/errors v0.8.1 // indirect
 	github.com/spf13/pflag v1.0.6
-	golang.org/x/net v0.8.0 // indirect
+	golang.org/x/net v0.21.0 // indirect
 	golang.org/x/oauth2 v0.6.0 // indirect
 	golang.org/x/sys v0.33.0 // indirect
 	golang.org/x/time v0.3.0 // indirect
@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"repo/internal/hoster"
+	"repo/internal/say"
+)
+
+// Listing is the persisted repository list of a hoster for a single request
+type Listing struct {
+	Host         string
+	Updated      time.Time
+	Repositories []hoster.HosterRepository
+}
+
+// Dir returns the cache directory, points in most cases to "${HOME}/.cache/repow"
+func Dir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "repow"), nil
+}
+
+// scope are the request options changing the repositories listed by the hoster, which can't be matched on the
+// listing afterwards. All other filters are matched on the cached listing, archived repositories are always listed.
+// The topics are part of the scope for hosters, which only know the requested topics of the repositories.
+func scope(options hoster.RequestOptions, topics bool) hoster.RequestOptions {
+	result := hoster.RequestOptions{
+		Starred:        options.Starred,
+		Groups:         options.Groups,
+		Owned:          options.Owned,
+		MinAccessLevel: options.MinAccessLevel,
+		Archived:       true,
+	}
+	if topics {
+		result.Topics = options.Topics
+	}
+	return result
+}
+
+// the listing is stored per host and scope of the request options, topics are only passed for partial topics hosters
+func file(host string, options hoster.RequestOptions) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	request, err := json.Marshal(scope(options, true))
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(request)
+	return filepath.Join(dir, strings.ReplaceAll(host, ":", "_"), hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// Load returns the cached listing, if it exists and is not older than maxAge
+func Load(host string, options hoster.RequestOptions, maxAge time.Duration) (*Listing, bool) {
+	name, err := file(host, options)
+	if err != nil {
+		say.Verbose("Unable to determine cache file: %s", err)
+		return nil, false
+	}
+	content, err := os.ReadFile(name)
+	if err != nil {
+		say.Verbose("No cached listing for %s: %s", host, err)
+		return nil, false
+	}
+	listing := &Listing{}
+	if err := json.Unmarshal(content, listing); err != nil {
+		say.Verbose("Invalid cached listing %s: %s", name, err)
+		return nil, false
+	}
+	if time.Since(listing.Updated) > maxAge {
+		say.Verbose("Cached listing for %s is outdated (%s)", host, listing.Updated.Format(time.RFC3339))
+		return nil, false
+	}
+	return listing, true
+}
+
+// Store persists the listing, failures are not fatal as the cache is optional
+func Store(host string, options hoster.RequestOptions, repos []hoster.HosterRepository) {
+	name, err := file(host, options)
+	if err != nil {
+		say.Verbose("Unable to determine cache file: %s", err)
+		return
+	}
+	content, err := json.Marshal(Listing{Host: host, Updated: time.Now(), Repositories: repos})
+	if err != nil {
+		say.Verbose("Unable to serialize listing: %s", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		say.Verbose("Unable to create cache directory: %s", err)
+		return
+	}
+	if err := os.WriteFile(name, content, 0644); err != nil {
+		say.Verbose("Unable to write cache file: %s", err)
+	}
+}
+
+// Repositories returns the repositories of the hoster. With caching enabled (maxAge or refresh) the listing of the
+// scope is taken from the cache, if it is younger than maxAge and no refresh is requested, otherwise it is retrieved
+// from the hoster and cached. The filters are matched on the listing then, so clone and cleanup share it.
+// Without caching the repositories are requested from the hoster as usual.
+func Repositories(h hoster.Hoster, options hoster.RequestOptions, maxAge time.Duration, refresh bool) []hoster.HosterRepository {
+	if maxAge <= 0 && !refresh {
+		return h.Repositories(options)
+	}
+	_, partialTopics := h.(hoster.PartialTopicsHoster)
+	request := scope(options, partialTopics)
+
+	var repos []hoster.HosterRepository
+	listing, exists := Load(h.Host(), request, maxAge)
+	if exists && !refresh {
+		say.InfoLn("Using cached %s repositories from %s (%d)", h.Name(), listing.Updated.Format(time.DateTime), len(listing.Repositories))
+		repos = listing.Repositories
+	} else {
+		repos = h.Repositories(request)
+		Store(h.Host(), request, repos)
+	}
+
+	// the listing is limited to the groups already, which might be given by their ID
+	matching := options
+	matching.Groups = nil
+	matching = hoster.WithKnownVisibility(h, matching)
+	var result []hoster.HosterRepository
+	for _, repo := range repos {
+		if (!repo.Archived || options.Archived) && hoster.Matches(matching, repo.PathWithNamespace, repo.Topics) && hoster.MatchesAttributes(matching, repo) {
+			result = append(result, repo)
+		}
+	}
+	return result
+}
+
+// States returns the state of all repositories in the (cached) listing of the hoster, keyed by the lowercase path
+func States(h hoster.Hoster, maxAge time.Duration, refresh bool) map[string]hoster.CleanupState {
+	result := map[string]hoster.CleanupState{}
+	for _, repo := range Repositories(h, hoster.RequestOptions{Archived: true}, maxAge, refresh) {
+		state := hoster.Ok
+		if repo.Archived {
+			state = hoster.Archived
+		}
+		result[strings.ToLower(repo.PathWithNamespace)] = state
+	}
+	return result
+}
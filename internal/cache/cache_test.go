@@ -0,0 +1,191 @@
+package cache
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"repo/internal/hoster"
+	"repo/internal/model"
+)
+
+func TestStoreLoad(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	options := hoster.RequestOptions{Starred: true, Archived: true}
+	repos := []hoster.HosterRepository{{Id: 1, PathWithNamespace: "group/project", Archived: true}}
+
+	if _, exists := Load("gitlab.com", options, time.Hour); exists {
+		t.Errorf("expected no listing before storing")
+	}
+	Store("gitlab.com", options, repos)
+
+	listing, exists := Load("gitlab.com", options, time.Hour)
+	if !exists || len(listing.Repositories) != 1 || !listing.Repositories[0].Archived {
+		t.Errorf("unexpected listing %v", listing)
+	}
+	if _, exists := Load("gitlab.com", hoster.RequestOptions{Archived: true}, time.Hour); exists {
+		t.Errorf("expected no listing for other scope")
+	}
+	if _, exists := Load("gitlab.com", hoster.RequestOptions{Starred: true, Visibility: "public"}, time.Hour); !exists {
+		t.Errorf("expected listing for options of the same scope")
+	}
+	if _, exists := Load("gitlab.com", hoster.RequestOptions{Starred: true, Topics: []string{"library"}}, time.Hour); exists {
+		t.Errorf("expected no listing for other topics")
+	}
+	if _, exists := Load("github.com", options, time.Hour); exists {
+		t.Errorf("expected no listing for other host")
+	}
+	if _, exists := Load("gitlab.com", options, 0); exists {
+		t.Errorf("expected outdated listing")
+	}
+}
+
+// listingHoster lists fixed repositories and counts the requests
+type listingHoster struct {
+	hoster.Hoster
+	repos    []hoster.HosterRepository
+	requests int
+}
+
+func (l *listingHoster) Host() string { return "gitlab.com" }
+func (l *listingHoster) Name() string { return "gitlab" }
+func (l *listingHoster) Repositories(options hoster.RequestOptions) []hoster.HosterRepository {
+	l.requests++
+	var result []hoster.HosterRepository
+	for _, repo := range l.repos {
+		if hoster.Matches(options, repo.PathWithNamespace, repo.Topics) && (options.Archived || !repo.Archived) {
+			result = append(result, repo)
+		}
+	}
+	return result
+}
+
+func TestRepositoriesShared(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	h := &listingHoster{repos: []hoster.HosterRepository{
+		{PathWithNamespace: "group/library", Topics: []string{"library"}},
+		{PathWithNamespace: "group/service"},
+		{PathWithNamespace: "group/legacy", Topics: []string{"library"}, Archived: true},
+	}}
+
+	if repos := Repositories(h, hoster.RequestOptions{Topics: []string{"library"}}, 0, false); len(repos) != 1 {
+		t.Errorf("unexpected repositories without cache %v", repos)
+	}
+	if _, exists := Load("gitlab.com", hoster.RequestOptions{}, time.Hour); exists {
+		t.Errorf("expected no listing to be stored without cache")
+	}
+
+	if repos := Repositories(h, hoster.RequestOptions{Topics: []string{"library"}}, time.Hour, false); len(repos) != 1 || repos[0].PathWithNamespace != "group/library" {
+		t.Errorf("unexpected filtered repositories %v", repos)
+	}
+	if repos := Repositories(h, hoster.RequestOptions{Archived: true}, time.Hour, false); len(repos) != 3 {
+		t.Errorf("unexpected repositories including archived %v", repos)
+	}
+	if h.requests != 2 {
+		t.Errorf("expected the listing to be shared, got %d requests", h.requests)
+	}
+}
+
+// labelingHoster only knows the topics of the repositories for the requested topics, like the Bitbucket labels
+type labelingHoster struct {
+	listingHoster
+}
+
+func (l *labelingHoster) PartialTopics() {}
+func (l *labelingHoster) Repositories(options hoster.RequestOptions) []hoster.HosterRepository {
+	var result []hoster.HosterRepository
+	for _, repo := range l.listingHoster.Repositories(options) {
+		var topics []string
+		for _, topic := range repo.Topics {
+			if slices.Contains(options.Topics, topic) {
+				topics = append(topics, topic)
+			}
+		}
+		repo.Topics = topics
+		result = append(result, repo)
+	}
+	return result
+}
+
+func TestRepositoriesPartialTopics(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	h := &labelingHoster{listingHoster{repos: []hoster.HosterRepository{
+		{PathWithNamespace: "group/library", Topics: []string{"library"}},
+		{PathWithNamespace: "group/service", Topics: []string{"service"}},
+	}}}
+
+	if repos := Repositories(h, hoster.RequestOptions{}, time.Hour, false); len(repos) != 2 {
+		t.Errorf("unexpected repositories without topics %v", repos)
+	}
+	if repos := Repositories(h, hoster.RequestOptions{Topics: []string{"library"}}, time.Hour, false); len(repos) != 1 || repos[0].PathWithNamespace != "group/library" {
+		t.Errorf("unexpected repositories for topic %v", repos)
+	}
+	if repos := Repositories(h, hoster.RequestOptions{Topics: []string{"service"}}, time.Hour, false); len(repos) != 1 || repos[0].PathWithNamespace != "group/service" {
+		t.Errorf("unexpected repositories for other topic %v", repos)
+	}
+	if h.requests != 3 {
+		t.Errorf("expected a listing per topics, got %d requests", h.requests)
+	}
+}
+
+// visibilityHoster only knows private and public repositories
+type visibilityHoster struct {
+	listingHoster
+}
+
+func (v *visibilityHoster) Visibilities() []string { return []string{"private", "public"} }
+
+func TestRepositoriesUnknownVisibility(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	h := &visibilityHoster{listingHoster{repos: []hoster.HosterRepository{
+		{PathWithNamespace: "group/private", Visibility: "private"},
+		{PathWithNamespace: "group/public", Visibility: "public"},
+	}}}
+
+	if repos := Repositories(h, hoster.RequestOptions{Visibility: "internal"}, time.Hour, false); len(repos) != 2 {
+		t.Errorf("expected unknown visibility to be ignored, got %v", repos)
+	}
+	if repos := Repositories(h, hoster.RequestOptions{Visibility: "public"}, time.Hour, false); len(repos) != 1 {
+		t.Errorf("unexpected repositories for known visibility %v", repos)
+	}
+}
+
+func TestStoreLoadManifests(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	if manifests := LoadManifests("gitlab.com"); len(manifests) != 0 {
+		t.Errorf("expected no manifests before storing, got %v", manifests)
+	}
+	StoreManifests("gitlab.com", map[string]Manifest{
+		"group/service": {Updated: time.Now(), RepoYaml: &model.RepoYaml{Type: "service"}},
+		"group/empty":   {Updated: time.Now()},
+	})
+
+	manifests := LoadManifests("gitlab.com")
+	if len(manifests) != 2 || manifests["group/service"].RepoYaml.Type != "service" || manifests["group/empty"].RepoYaml != nil {
+		t.Errorf("unexpected manifests %v", manifests)
+	}
+	if manifests := LoadManifests("github.com"); len(manifests) != 0 {
+		t.Errorf("expected no manifests for other host, got %v", manifests)
+	}
+}
+
+func TestManifestValid(t *testing.T) {
+	manifest := Manifest{Updated: time.Now().Add(-2 * time.Hour)}
+	if !manifest.valid(hoster.HosterRepository{}, 3*time.Hour) {
+		t.Errorf("expected manifest younger than max-age to be valid")
+	}
+	if manifest.valid(hoster.HosterRepository{}, time.Hour) {
+		t.Errorf("expected outdated manifest without activity to be invalid")
+	}
+	if !manifest.valid(hoster.HosterRepository{LastActivity: time.Now().Add(-3 * time.Hour)}, time.Hour) {
+		t.Errorf("expected manifest downloaded after the last activity to be valid")
+	}
+	if manifest.valid(hoster.HosterRepository{LastActivity: time.Now().Add(-time.Hour)}, time.Hour) {
+		t.Errorf("expected manifest downloaded before the last activity to be invalid")
+	}
+}
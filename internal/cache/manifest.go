@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"repo/internal/hoster"
+	"repo/internal/model"
+	"repo/internal/say"
+)
+
+// Manifest is the persisted repo.yaml of a repository, RepoYaml is nil if the repository has no (valid) repo.yaml
+type Manifest struct {
+	Updated  time.Time
+	RepoYaml *model.RepoYaml
+}
+
+// the manifests are stored in a single file per host, keyed by the path of the repository
+func manifestsFile(host string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, strings.ReplaceAll(host, ":", "_"), "manifests.json"), nil
+}
+
+// LoadManifests returns all cached manifests of the host
+func LoadManifests(host string) map[string]Manifest {
+	result := map[string]Manifest{}
+	name, err := manifestsFile(host)
+	if err != nil {
+		say.Verbose("Unable to determine cache file: %s", err)
+		return result
+	}
+	content, err := os.ReadFile(name)
+	if err != nil {
+		say.Verbose("No cached manifests for %s: %s", host, err)
+		return result
+	}
+	if err := json.Unmarshal(content, &result); err != nil {
+		say.Verbose("Invalid cached manifests %s: %s", name, err)
+		return map[string]Manifest{}
+	}
+	return result
+}
+
+// StoreManifests persists the manifests of the host, failures are not fatal as the cache is optional
+func StoreManifests(host string, manifests map[string]Manifest) {
+	name, err := manifestsFile(host)
+	if err != nil {
+		say.Verbose("Unable to determine cache file: %s", err)
+		return
+	}
+	content, err := json.Marshal(manifests)
+	if err != nil {
+		say.Verbose("Unable to serialize manifests: %s", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		say.Verbose("Unable to create cache directory: %s", err)
+		return
+	}
+	if err := os.WriteFile(name, content, 0644); err != nil {
+		say.Verbose("Unable to write cache file: %s", err)
+	}
+}
+
+// a cached manifest is used, if it is younger than maxAge or was downloaded after the last activity of the repository
+func (m Manifest) valid(repo hoster.HosterRepository, maxAge time.Duration) bool {
+	if maxAge > 0 && time.Since(m.Updated) <= maxAge {
+		return true
+	}
+	return !repo.LastActivity.IsZero() && m.Updated.After(repo.LastActivity)
+}
+
+// Manifests returns the repo.yaml of the repositories from the default branch, keyed by the path of the repository.
+// Cached manifests are used unless a refresh is requested, the others are downloaded in parallel and cached.
+// Repositories without repo.yaml have a nil value, repositories failing to download are missing in the result.
+func Manifests(h hoster.Hoster, repos []hoster.HosterRepository, maxAge time.Duration, refresh bool, parallelism int) map[string]*model.RepoYaml {
+	cached := LoadManifests(h.Host())
+	result := map[string]*model.RepoYaml{}
+
+	var outdated []hoster.HosterRepository
+	for _, repo := range repos {
+		if manifest, exists := cached[repo.PathWithNamespace]; exists && !refresh && manifest.valid(repo, maxAge) {
+			result[repo.PathWithNamespace] = manifest.RepoYaml
+		} else {
+			outdated = append(outdated, repo)
+		}
+	}
+	say.Info("Downloading %s manifests (%d cached)", model.RepoYamlFilename, len(repos)-len(outdated))
+
+	tasks := make(chan hoster.HosterRepository)
+	var downloaded int
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < max(1, parallelism); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for repo := range tasks {
+				repoYaml, valid, err := h.DownloadRepoyaml(repo.PathWithNamespace, repo.DefaultBranch)
+				if err != nil && !errors.Is(err, hoster.ErrManifestMissing) {
+					say.Error("\nUnable to download %s for %s: %s", model.RepoYamlFilename, repo.PathWithNamespace, err)
+					continue
+				}
+				if !valid {
+					repoYaml = nil
+				}
+				mutex.Lock()
+				result[repo.PathWithNamespace] = repoYaml
+				cached[repo.PathWithNamespace] = Manifest{Updated: time.Now(), RepoYaml: repoYaml}
+				downloaded++
+				mutex.Unlock()
+			}
+		}()
+	}
+	for _, repo := range outdated {
+		tasks <- repo
+	}
+	close(tasks)
+	wg.Wait()
+	say.InfoLn(" %d downloaded (%d failed)\n", downloaded, len(outdated)-downloaded)
+
+	if downloaded > 0 {
+		StoreManifests(h.Host(), cached)
+	}
+	return result
+}
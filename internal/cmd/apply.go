@@ -3,7 +3,6 @@ package cmd
 import (
 	"repo/internal/config"
 	"repo/internal/hoster"
-	"repo/internal/hoster/gitlab"
 	"repo/internal/model"
 	"repo/internal/say"
 	"time"
@@ -18,27 +17,35 @@ func init() {
 	rootCmd.AddCommand(applyCmd)
 	applyCmd.Flags().BoolVarP(&applyOptionalContacts, "optionalContacts", "e", false, "Allow empty contacts (existing contacts still will be validated)")
 	applyCmd.Flags().BoolVarP(&applyOptionalManifest, "optionalManifest", "m", false, "Allow repositories not containing a manifest file")
+	addSelectionFlags(applyCmd, "")
+	addCacheFlags(applyCmd)
+	addDryRunFlag(applyCmd)
 }
 
 var applyCmd = &cobra.Command{
 	Use:   "apply [dir]",
 	Short: "Applies the repo.yaml configuration to the hosters repository settings",
-	Long:  `Applies the repositories repo.yaml manifest file configuration to the hosters repository settings`,
+	Long:  `Applies the repositories repo.yaml manifest file configuration to the hosters repository settings. With --dry-run the planned API edits are printed instead.`,
 	Args:  validateConditions(cobra.ExactArgs(1), validateArgGitDir(0, true, true)),
 	Run: func(cmd *cobra.Command, args []string) {
 		config.Init(cmd.Flags())
-		hoster, err := gitlab.MakeHoster()
+		hosters, err := makeHosters()
 		handleFatalError(err)
 
 		dirReposRoot := getAbsoluteRepoRoot(args[0])
-		gitDirs := collectGitDirsHandled(dirReposRoot, hoster)
-		applyProcess(hoster, gitDirs)
+		gitDirs := selectGitDirs(cmd, dirReposRoot, collectGitDirsHandled(dirReposRoot, hosters), hosters)
+		applyProcess(hosters, gitDirs)
 	},
 }
 
-func applyProcess(hoster hoster.Hoster, gitDirs []model.RepoDir) {
+func applyProcess(hosters hoster.Hosters, gitDirs []model.RepoDir) {
 	defer say.Timer(time.Now())
 	for _, gd := range gitDirs {
+		hoster := hosters.ByHost(gd.Host)
+		if hoster == nil {
+			say.InfoLn("Skipping %s, unable to determine hoster", gd.Name)
+			continue
+		}
 		// validate
 		errs := hoster.Validate(gd.RepoMeta, config.Values.Options.OptionalManifest, config.Values.Options.OptionalContacts)
 		if errs != nil {
@@ -5,11 +5,12 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"repo/internal/cache"
 	"repo/internal/config"
 	h "repo/internal/hoster"
-	"repo/internal/hoster/gitlab"
 	"repo/internal/model"
 	"repo/internal/say"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -25,6 +26,9 @@ func init() {
 	rootCmd.AddCommand(cleanupCmd)
 	cleanupCmd.Flags().BoolVarP(&cleanupQuiet, "quiet", "q", false, "Output only affected repositories")
 	cleanupCmd.Flags().IntVarP(&cleanupParallelism, "parallelism", "p", 32, "How many process should run in parallel, 1 would be no parallelism.")
+	addSelectionFlags(cleanupCmd, "e")
+	addCacheFlags(cleanupCmd)
+	addDryRunFlag(cleanupCmd)
 }
 
 var cleanupCmd = &cobra.Command{
@@ -33,19 +37,55 @@ var cleanupCmd = &cobra.Command{
 	Long:  `Archived or deleted repositories at the hoster are moved aside from the checkout-directory. They are collected non-destructive into separate directories.`,
 	Args:  validateConditions(cobra.ExactArgs(1), validateArgGitDir(0, false, true)),
 	Run: func(cmd *cobra.Command, args []string) {
+		config.WorkspaceDir = args[0]
 		config.Init(cmd.Flags())
 		dirReposRoot := getAbsoluteRepoRoot(args[0])
+		if config.Values.Options.Template != "" {
+			_, err := targetTemplate()
+			handleFatalError(err)
+		}
+		config.Values.Options.Mirror = false // determined per repository
 
-		hoster, err := gitlab.MakeHoster()
+		hosters, err := makeHosters()
 		handleFatalError(err)
 
-		gitDirs := collectGitDirsHandled(dirReposRoot, hoster)
+		gitDirs := selectGitDirs(cmd, dirReposRoot, collectGitDirsHandled(dirReposRoot, hosters), hosters)
 
-		checkRepositories(dirReposRoot, gitDirs, hoster)
+		checkRepositories(dirReposRoot, gitDirs, hosters, knownStates(hosters, gitDirs))
 	},
 }
 
-func checkRepositories(dirReposRoot string, dirs []model.RepoDir, hoster h.Hoster) {
+// knownStates retrieves the states per hoster at once instead of a request per repository, either from the (cached)
+// listing when the cache is enabled, or with a bulk lookup if the hoster supports it. The states are keyed by host
+// and lowercase remote path, repositories without a known state are requested one by one.
+func knownStates(hosters h.Hosters, gitDirs []model.RepoDir) map[string]map[string]h.CleanupState {
+	options := config.Values.Options
+	result := map[string]map[string]h.CleanupState{}
+	for _, hoster := range usedHosters(hosters, gitDirs) {
+		if options.MaxAge > 0 || options.Refresh {
+			result[hoster.Host()] = cache.States(hoster, options.MaxAge, options.Refresh)
+			continue
+		}
+		bulk, ok := hoster.(h.BulkHoster)
+		if !ok {
+			continue
+		}
+		var paths []string
+		for _, gd := range gitDirs {
+			if gd.Host == hoster.Host() && gd.RemotePath != "" {
+				paths = append(paths, gd.RemotePath)
+			}
+		}
+		states := map[string]h.CleanupState{}
+		for remotePath, state := range bulk.ProjectStates(paths) {
+			states[strings.ToLower(remotePath)] = state
+		}
+		result[hoster.Host()] = states
+	}
+	return result
+}
+
+func checkRepositories(dirReposRoot string, dirs []model.RepoDir, hosters h.Hosters, listed map[string]map[string]h.CleanupState) {
 	counter := int32(0)
 	counterOk := int32(0)
 	counterSkipped := int32(0)
@@ -61,7 +101,7 @@ func checkRepositories(dirReposRoot string, dirs []model.RepoDir, hoster h.Hoste
 	var wg sync.WaitGroup
 	for i := 0; i < getParallelism(config.Values.Options.Parallelism); i++ {
 		wg.Add(1)
-		go processDir(dirReposRoot, hoster, &counter, len(dirs), &counterOk, &counterSkipped, &counterArchived, &counterRemoved, tasks, &wg)
+		go processDir(dirReposRoot, hosters, listed, &counter, len(dirs), &counterOk, &counterSkipped, &counterArchived, &counterRemoved, tasks, &wg)
 	}
 
 	for _, dirRepository := range dirs {
@@ -71,22 +111,27 @@ func checkRepositories(dirReposRoot string, dirs []model.RepoDir, hoster h.Hoste
 	wg.Wait()
 }
 
-func processDir(dirReposRoot string, hoster h.Hoster, counter *int32, total int, counterOk *int32, counterSkipped *int32, counterArchived *int32, counterRemoved *int32, tasks chan model.RepoDir, wg *sync.WaitGroup) {
+func processDir(dirReposRoot string, hosters h.Hosters, listed map[string]map[string]h.CleanupState, counter *int32, total int, counterOk *int32, counterSkipped *int32, counterArchived *int32, counterRemoved *int32, tasks chan model.RepoDir, wg *sync.WaitGroup) {
 	defer wg.Done()
 	for dirRepository := range tasks {
 
 		dirRepoRelative := getRelativRepoDir(dirRepository.Path, dirReposRoot)
-		remotePath := model.DetermineRemotePath(dirRepository.Path, hoster.Host())
-		webUrl := "https://" + hoster.Host() + "/" + remotePath
+		remotePath := dirRepository.RemotePath
+		webUrl := getWebUrl(dirRepository.Host, remotePath)
 
-		if remotePath == "" {
+		hoster := hosters.ByHost(dirRepository.Host)
+		if remotePath == "" || hoster == nil {
 			say.ProgressWarn(counter, total, nil, dirRepoRelative, webUrl, "- Unable to determine git remote name (skipping)")
 			atomic.AddInt32(counterSkipped, 1)
 			continue
 		}
 
 		say.Verbose("RemotePath: %s: %s", dirRepoRelative, remotePath)
-		state, err := hoster.ProjectState(remotePath)
+		state, exists := listed[dirRepository.Host][strings.ToLower(remotePath)]
+		var err error
+		if !exists {
+			state, err = hoster.ProjectState(remotePath)
+		}
 		if err != nil {
 			say.ProgressWarn(counter, total, err, dirRepoRelative, webUrl, "- Unable to determine git remote state (skipping)")
 			atomic.AddInt32(counterSkipped, 1)
@@ -94,6 +139,7 @@ func processDir(dirReposRoot string, hoster h.Hoster, counter *int32, total int,
 		}
 		say.Verbose("State for %s: %v", dirRepoRelative, state)
 
+		dirMoved := movedRepoDir(dirRepository, dirRepoRelative)
 		var errorMove error = nil
 		var code string = ""
 		switch state {
@@ -102,11 +148,11 @@ func processDir(dirReposRoot string, hoster h.Hoster, counter *int32, total int,
 			code = color.Green("✔").Bold().String()
 			atomic.AddInt32(counterOk, 1)
 		case h.Archived:
-			errorMove = move(dirReposRoot, dirRepository.Path, dirArchived)
+			errorMove = move(dirReposRoot, dirRepository.Path, dirArchived, dirMoved)
 			code = color.Blue("A").Bold().String() // 📦
 			atomic.AddInt32(counterArchived, 1)
 		case h.Removed:
-			errorMove = move(dirReposRoot, dirRepository.Path, dirRemoved)
+			errorMove = move(dirReposRoot, dirRepository.Path, dirRemoved, dirMoved)
 			code = color.Cyan("R").Bold().String() // 🗑
 			atomic.AddInt32(counterRemoved, 1)
 		default:
@@ -118,6 +164,8 @@ func processDir(dirReposRoot string, hoster h.Hoster, counter *int32, total int,
 
 		if errorMove != nil {
 			say.ProgressError(counter, total, errorMove, dirRepoRelative, webUrl, "- Unable to move")
+		} else if config.Values.Options.DryRun && state != h.Ok {
+			say.ProgressGeneric(counter, total, code, dirRepoRelative, webUrl, "- %s [dry-run]", path.Join(movedDir(state), dirMoved))
 		} else {
 			if !cleanupQuiet || state != h.Ok {
 				say.ProgressGeneric(counter, total, code, dirRepoRelative, webUrl, "")
@@ -126,13 +174,45 @@ func processDir(dirReposRoot string, hoster h.Hoster, counter *int32, total int,
 	}
 }
 
-func move(dirReposRoot string, dirRepository string, dirTarget string) error {
+// directory the repositories with the state are moved into
+func movedDir(state h.CleanupState) string {
+	if state == h.Archived {
+		return dirArchived
+	}
+	return dirRemoved
+}
+
+// relative directory of the repository within the directory it is moved into, following the path template if set.
+// Keeps the relative directory if the template can't be applied (eg. topics of a removed repository are unknown).
+func movedRepoDir(dirRepository model.RepoDir, dirRepoRelative string) string {
+	if config.Values.Options.Template == "" {
+		return dirRepoRelative
+	}
+	target, err := getRepoDirTargetDir(dirRepository)
+	if err != nil {
+		say.Verbose("Unable to apply path template to %s, keeping the directory: %s", dirRepoRelative, err)
+		return dirRepoRelative
+	}
+	return target
+}
+
+func move(dirReposRoot string, dirRepository string, dirTarget string, dirMoved string) error {
 	dirRepoRelative := getRelativRepoDir(dirRepository, dirReposRoot)
 	dirAbsSource := dirRepository
 	dirAbsTarget := path.Join(dirReposRoot, dirTarget)
-	dirAbsTargetRepository := path.Join(dirReposRoot, dirTarget, dirRepoRelative)
+	dirAbsTargetRepository := path.Join(dirReposRoot, dirTarget, dirMoved)
 
-	// check & prepare target
+	// check target
+	if fiTarget, err := os.Stat(dirAbsTarget); err == nil && !fiTarget.IsDir() {
+		return errors.New("directory in target location is not a directory (skipping)")
+	}
+	// check if target-repository dir already exists
+	if _, err := os.Stat(dirAbsTargetRepository); err == nil {
+		return errors.New("directory in target location already exists (skipping)")
+	}
+	if config.Values.Options.DryRun {
+		return nil
+	}
 	// create if not exists
 	if _, err := os.Stat(dirAbsTarget); os.IsNotExist(err) {
 		errMk := os.Mkdir(dirAbsTarget, 0775)
@@ -140,15 +220,6 @@ func move(dirReposRoot string, dirRepository string, dirTarget string) error {
 			return errMk
 		}
 	}
-	// check if target is directory
-	fiTarget, _ := os.Stat(dirAbsTarget)
-	if !fiTarget.IsDir() {
-		return errors.New("directory in target location is not a directory (skipping)")
-	}
-	// check if target-repository dir already exists
-	if _, err := os.Stat(dirAbsTargetRepository); err == nil {
-		return errors.New("directory in target location already exists (skipping)")
-	}
 
 	//move
 	say.Verbose("Moving %s to %s\n", dirRepoRelative, dirAbsTargetRepository)
@@ -1,76 +1,316 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path"
+	"repo/internal/cache"
 	"repo/internal/config"
 	"repo/internal/gitclient"
 	h "repo/internal/hoster"
-	"repo/internal/hoster/gitlab"
+	"repo/internal/layout"
+	"repo/internal/model"
 	"repo/internal/say"
+	"repo/internal/selector"
+	"repo/internal/util"
+	"slices"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	color "github.com/logrusorgru/aurora/v4"
 	"github.com/spf13/cobra"
 )
 
 var cloneStyle string
-var cloneTopics []string
-var cloneExcludePatterns []string
-var cloneIncludePatterns []string
+var cloneArchived bool
+var cloneSave bool
 
 var cloneParallelism int
-var cloneStarred bool
+var cloneHostPrefix bool
 
 func init() {
 	rootCmd.AddCommand(cloneCmd)
 	cloneCmd.Flags().StringVarP(&cloneStyle, "style", "y", "flat", "Either repositories are cloned 'flat' into the root-dir, or 'recursive' using the groups as directories.")
-	cloneCmd.Flags().StringSliceVarP(&cloneTopics, "topic", "t", nil, "Topics (aka tags/labels) to be filtered. Multiple topics are possible (and).")
-	cloneCmd.Flags().StringSliceVarP(&cloneExcludePatterns, "exclude", "e", nil, "Regex-pattern not to be matched for the path. Multiple patterns are possible (and).")
-	cloneCmd.Flags().StringSliceVarP(&cloneIncludePatterns, "include", "i", nil, "Regex-pattern that needs to be matched for the path. Multiple patterns are possible (or).")
+	cloneCmd.Flags().StringP("template", "", "", "Path template for the target directory instead of the style, eg. '{{.Namespace | trimPrefix \"acme/\"}}/{{.Path}}'.")
+	cloneCmd.Flags().StringSliceP("topic", "t", nil, "Topics (aka tags/labels) to be filtered. Multiple topics are possible (and).")
+	cloneCmd.Flags().StringSliceP("exclude", "e", nil, "Regex-pattern not to be matched for the path. Multiple patterns are possible (and).")
+	cloneCmd.Flags().StringSliceP("include", "i", nil, "Regex-pattern that needs to be matched for the path. Multiple patterns are possible (or).")
+	cloneCmd.Flags().StringSliceP("group", "g", nil, "Group path (or Gitlab group ID) to be cloned including its subgroups. Multiple groups are possible (or).")
+	cloneCmd.Flags().BoolVarP(&cloneArchived, "archived", "", false, "Include archived projects")
+	cloneCmd.Flags().StringP("visibility", "", "", "Filter for the visibility, either 'private', 'internal' or 'public'.")
+	cloneCmd.Flags().BoolP("no-forks", "", false, "Exclude forked projects")
+	cloneCmd.Flags().BoolP("owned", "", false, "Filter for projects owned by the user")
+	cloneCmd.Flags().StringP("min-access-level", "", "", "Filter for projects with at least the access level, one of 'guest', 'reporter', 'developer', 'maintainer' or 'owner'.")
+	cloneCmd.Flags().StringP("active-since", "", "", "Filter for projects with activity within the duration, eg. '90d', '2w' or '12h'.")
+	cloneCmd.Flags().StringP("type", "", "", "Filter for the type in the repo.yaml, eg. 'service'.")
+	cloneCmd.Flags().StringSliceP("org", "", nil, "Filter for an org entry in the repo.yaml, eg. 'squad=user'. Multiple values for the same key are possible (or), different keys are combined (and).")
+	cloneCmd.Flags().StringSliceP("annotation", "", nil, "Filter for an annotation in the repo.yaml, eg. 'acme.corp/access=iam'. Multiple values for the same key are possible (or), different keys are combined (and).")
+	cloneCmd.Flags().StringSliceP("contact", "", nil, "Filter for a contact listed in the repo.yaml. Multiple contacts are possible (or).")
+	addWhereFlag(cloneCmd)
 	cloneCmd.Flags().IntVarP(&cloneParallelism, "parallelism", "p", 32, "How many process should run in parallel, 1 would be no parallelism.")
-	cloneCmd.Flags().BoolVarP(&cloneStarred, "starred", "s", false, "Filter for starred projects")
+	cloneCmd.Flags().BoolP("starred", "s", false, "Filter for starred projects")
+	cloneCmd.Flags().BoolVarP(&cloneSave, "save", "", false, "Store the hoster, layout and filters in the workspace file "+config.WorkspaceFilename+" of the root-dir, which is used by later runs.")
+	cloneCmd.Flags().BoolVarP(&cloneHostPrefix, "host-prefix", "", false, "Prefix the path with the hosts name when using the 'recursive' style.")
+	cloneCmd.Flags().IntP("depth", "", 0, "Create shallow clones with a history truncated to the number of commits.")
+	cloneCmd.Flags().StringP("filter", "", "", "Create partial clones with the filter 'blob:none' (without file contents) or 'tree:0' (without trees), which are fetched on demand.")
+	cloneCmd.Flags().BoolP("single-branch", "", false, "Clone only the history of the default branch.")
+	cloneCmd.Flags().BoolP("mirror", "", false, "Create bare mirrors into '<path>.git', eg. for backups.")
+	addTransportFlag(cloneCmd)
+	addCacheFlags(cloneCmd)
+	addDryRunFlag(cloneCmd)
 }
 
 var cloneCmd = &cobra.Command{
 	Use:   "clone [root-dir]",
 	Short: "Clones selected repositories to the passed location. Adds new ones on reoccurring calls.",
-	Long:  `Clones selected repositories to the passed location. Adds new ones on reoccurring calls.`,
-	Args:  validateConditions(cobra.ExactArgs(1), validateArgGitDir(0, false, true)),
+	Long: `Clones selected repositories to the passed location. Adds new ones on reoccurring calls.
+With --save the hoster, layout and filters are stored in the workspace file ` + config.WorkspaceFilename + ` of the root-dir, they are used by later runs of clone (update and cleanup use the hoster and layout) unless overridden by flags.`,
+	Args: validateConditions(cobra.ExactArgs(1), validateArgGitDir(0, false, true)),
 	Run: func(cmd *cobra.Command, args []string) {
+		config.WorkspaceDir = args[0]
 		config.Init(cmd.Flags())
 		defer say.Timer(time.Now())
 		dirReposRoot := getAbsoluteRepoRoot(args[0])
 
-		hoster, err := gitlab.MakeHoster()
+		hoster, err := makeHoster()
 		handleFatalError(err)
+		validateTransport()
+		if filter := config.Values.Options.Filter; filter != "" && !slices.Contains(gitclient.CloneFilters, filter) {
+			handleFatalError(fmt.Errorf("invalid value for filter: %q (available: %s)", filter, gitclient.CloneFilters))
+		}
 
-		gitclient.PrepareSsh(hoster.Host(), config.Values.Gitlab.SSHUser, config.Values.Gitlab.SSHPort)
-		repos := hoster.Repositories(h.RequestOptions{
-			Topics:          cloneTopics,
-			Starred:         cloneStarred,
-			ExcludePatterns: cloneExcludePatterns,
-			IncludePatterns: cloneIncludePatterns,
-		})
+		if config.Values.Options.Template != "" {
+			_, err := targetTemplate()
+			handleFatalError(err)
+		}
+		options, err := requestOptions(config.Values.Filter)
+		handleFatalError(err)
+		options.Archived = cloneArchived
+		manifestFilter, err := manifestOptions(config.Values.Filter)
+		handleFatalError(err)
+		where := parseWhere(config.Values.Filter.Where)
+		if (!manifestFilter.IsEmpty() || where != nil && where.Uses(selector.ManifestKeys...)) && !supportsManifests(hoster) {
+			handleFatalError(fmt.Errorf("filtering by the %s is not supported by plain git hosters (%s)", model.RepoYamlFilename, hoster.Name()))
+		}
+		if _, partialTopics := hoster.(h.PartialTopicsHoster); partialTopics && where != nil && where.Uses("topic") {
+			handleFatalError(fmt.Errorf("topics in --where are not supported by %s, only the topics of --topic are listed", hoster.Name()))
+		}
+
+		dryRun := config.Values.Options.DryRun
+		if config.Values.Options.Transport == config.TransportSsh && !dryRun {
+			sshUser, sshPort := hoster.SshAccess()
+			gitclient.PrepareSsh(hoster.Host(), sshUser, sshPort)
+		}
+		repos := cache.Repositories(hoster, options, config.Values.Options.MaxAge, config.Values.Options.Refresh)
+		if !manifestFilter.IsEmpty() {
+			repos = filterManifests(hoster, manifestFilter, repos)
+		}
+		if where != nil {
+			repos = selectListed(hoster, where, repos)
+		}
 		sort.Slice(repos, func(i, j int) bool {
 			return repos[i].PathWithNamespace < repos[j].PathWithNamespace
 		})
 
-		repos = filterExisting(dirReposRoot, repos)
-		cloneAll(dirReposRoot, repos)
+		targets, err := resolveTargetDirs(hoster.Host(), repos)
+		handleFatalError(err)
+
+		if cloneSave && dryRun {
+			say.InfoLn("Workspace file %s would be stored [dry-run]", config.WorkspaceFilename)
+		} else if cloneSave {
+			handleFatalError(config.WriteWorkspace(dirReposRoot))
+			say.Verbose("Stored workspace file in %s", dirReposRoot)
+		}
+
+		repos = filterExisting(dirReposRoot, targets, repos)
+		if dryRun {
+			printPlannedClones(targets, repos)
+			return
+		}
+		cloneAll(dirReposRoot, targets, repos)
 	},
 }
 
-func filterExisting(dirReposRoot string, repos []h.HosterRepository) (result []h.HosterRepository) {
-	for _, r := range repos {
-		var dirRepository string
-		switch config.Values.Options.Style {
-		case config.StyleFlat:
-			dirRepository = path.Join(dirReposRoot, r.Path)
-		case config.StyleRecursive:
-			dirRepository = path.Join(dirReposRoot, r.PathWithNamespace)
+// requestOptions validates the filter and converts it into the options for the hoster
+func requestOptions(filter config.Filter) (h.RequestOptions, error) {
+	result := h.RequestOptions{
+		Topics:          filter.Topics,
+		Starred:         filter.Starred,
+		ExcludePatterns: filter.Exclude,
+		IncludePatterns: filter.Include,
+		Groups:          filter.Groups,
+		Visibility:      filter.Visibility,
+		NoForks:         filter.NoForks,
+		Owned:           filter.Owned,
+		MinAccessLevel:  filter.MinAccessLevel,
+	}
+	if filter.Visibility != "" && !slices.Contains(h.Visibilities, filter.Visibility) {
+		return result, fmt.Errorf("invalid value for visibility: %q (available: %s)", filter.Visibility, h.Visibilities)
+	}
+	if filter.MinAccessLevel != "" && !slices.Contains(h.AccessLevels, filter.MinAccessLevel) {
+		return result, fmt.Errorf("invalid value for min-access-level: %q (available: %s)", filter.MinAccessLevel, h.AccessLevels)
+	}
+	if filter.ActiveSince != "" {
+		activeSince, err := util.ParseDuration(filter.ActiveSince)
+		if err != nil {
+			return result, fmt.Errorf("invalid value for active-since: %s", err)
+		}
+		result.ActiveSince = activeSince
+	}
+	return result, nil
+}
+
+// manifestOptions converts the repo.yaml filters, org and annotations are passed as "key=value"
+func manifestOptions(filter config.Filter) (h.ManifestOptions, error) {
+	result := h.ManifestOptions{Type: filter.Type, Contacts: filter.Contacts}
+	var err error
+	if result.Org, err = parseKeyValues("org", filter.Org); err != nil {
+		return result, err
+	}
+	if result.Annotations, err = parseKeyValues("annotation", filter.Annotations); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// groups the values of "key=value" pairs by their key
+func parseKeyValues(name string, pairs []string) (map[string][]string, error) {
+	result := map[string][]string{}
+	for _, pair := range pairs {
+		key, value, found := strings.Cut(pair, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid value for %s: %q (expected key=value)", name, pair)
+		}
+		result[key] = append(result[key], value)
+	}
+	return result, nil
+}
+
+// filterManifests keeps the repositories with a matching repo.yaml, the manifests are downloaded or taken from the cache
+func filterManifests(hoster h.Hoster, options h.ManifestOptions, repos []h.HosterRepository) []h.HosterRepository {
+	manifests := cache.Manifests(hoster, repos, config.Values.Options.MaxAge, config.Values.Options.Refresh, getParallelism(config.Values.Options.Parallelism))
+	var result []h.HosterRepository
+	for _, repo := range repos {
+		if h.MatchesManifest(options, manifests[repo.PathWithNamespace]) {
+			result = append(result, repo)
 		}
+	}
+	say.InfoLn("%d repositories matching the %s filters (%d filtered)", len(result), model.RepoYamlFilename, len(repos)-len(result))
+	return result
+}
+
+// the path template of the configuration, parsed once for all repositories
+var targetTemplate = sync.OnceValues(func() (*layout.Template, error) {
+	return layout.Parse(config.Values.Options.Template)
+})
+
+// relative directory the repository is cloned into, depending on the path template or style. Mirrors get the ".git" suffix.
+func getTargetDir(host string, repo h.HosterRepository) (string, error) {
+	var result string
+	switch {
+	case config.Values.Options.Template != "":
+		tmpl, err := targetTemplate()
+		if err != nil {
+			return "", err
+		}
+		result, err = tmpl.Execute(layout.MakeData(host, repo.PathWithNamespace, repo.Path, repo.Name, repo.Topics))
+		if err != nil {
+			return "", err
+		}
+		if top, _, _ := strings.Cut(result, "/"); top == dirArchived || top == dirRemoved {
+			return "", fmt.Errorf("path template results in the reserved directory %s", top)
+		}
+	case config.Values.Options.Style == config.StyleRecursive:
+		if config.Values.Options.HostPrefix {
+			result = path.Join(host, repo.PathWithNamespace)
+		} else {
+			result = repo.PathWithNamespace
+		}
+	default:
+		result = repo.Path
+	}
+	if config.Values.Options.Mirror {
+		result = result + ".git"
+	}
+	return result, nil
+}
+
+// relative directory of a local repository in the layout of the path template or style, determined by its origin remote
+func getRepoDirTargetDir(gd model.RepoDir) (string, error) {
+	repo := h.HosterRepository{Name: gd.Name, Path: path.Base(gd.RemotePath), PathWithNamespace: gd.RemotePath}
+	if gd.RepoYaml != nil && gd.RepoYamlValid {
+		repo.Topics = gd.RepoYaml.Topics
+	}
+	target, err := getTargetDir(gd.Host, repo)
+	if err != nil {
+		return "", err
+	}
+	if gd.Bare {
+		target = target + ".git"
+	}
+	return target, nil
+}
+
+// resolveTargetDirs determines the target directories by the path of the repositories. Repositories without
+// target directory or with the same target directory are reported, none of the repositories is cloned then.
+func resolveTargetDirs(host string, repos []h.HosterRepository) (map[string]string, error) {
+	result := map[string]string{}
+	var failed bool
+	for _, repo := range repos {
+		target, err := getTargetDir(host, repo)
+		if err != nil {
+			say.Error("Unable to determine target directory for %s: %s", repo.PathWithNamespace, err)
+			failed = true
+			continue
+		}
+		result[repo.PathWithNamespace] = target
+	}
+
+	collisions := layout.Collisions(result)
+	var targets []string
+	for target := range collisions {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	for _, target := range targets {
+		say.Error("Target directory %s is used by multiple repositories: %s", target, strings.Join(collisions[target], ", "))
+		failed = true
+	}
+
+	if failed {
+		return nil, errors.New("target directories are missing or not unique, adjust the style, path template or filters (nothing cloned)")
+	}
+	return result, nil
+}
+
+// options for shallow and partial clones
+func cloneOptions() gitclient.CloneOptions {
+	result := gitclient.CloneOptions{
+		Depth:        config.Values.Options.Depth,
+		Filter:       config.Values.Options.Filter,
+		SingleBranch: config.Values.Options.SingleBranch,
+		Mirror:       config.Values.Options.Mirror,
+	}
+	if config.Values.Options.Transport == config.TransportHttps {
+		result.CredentialHelper = credentialHelper()
+	}
+	return result
+}
+
+// url of the repository for the configured transport
+func cloneUrl(repo h.HosterRepository) string {
+	if config.Values.Options.Transport == config.TransportHttps {
+		return repo.HttpUrl
+	}
+	return repo.SshUrl
+}
+
+func filterExisting(dirReposRoot string, targets map[string]string, repos []h.HosterRepository) (result []h.HosterRepository) {
+	for _, r := range repos {
+		dirRepository := path.Join(dirReposRoot, targets[r.PathWithNamespace])
 
 		_, err := os.Stat(dirRepository)
 		if os.IsNotExist(err) {
@@ -82,13 +322,20 @@ func filterExisting(dirReposRoot string, repos []h.HosterRepository) (result []h
 	return
 }
 
-func cloneAll(dirReposRoot string, repos []h.HosterRepository) {
+func printPlannedClones(targets map[string]string, repos []h.HosterRepository) {
+	counter := int32(0)
+	for _, repo := range repos {
+		say.ProgressGeneric(&counter, len(repos), color.Blue("↓").Bold().String(), repo.PathWithNamespace, repo.WebUrl, "- %s [dry-run]", targets[repo.PathWithNamespace])
+	}
+}
+
+func cloneAll(dirReposRoot string, targets map[string]string, repos []h.HosterRepository) {
 	tasks := make(chan h.HosterRepository)
 	var wg sync.WaitGroup
 	counter := int32(0)
 	for i := 0; i < getParallelism(config.Values.Options.Parallelism); i++ {
 		wg.Add(1)
-		go clone(dirReposRoot, &counter, len(repos), tasks, &wg)
+		go clone(dirReposRoot, targets, &counter, len(repos), tasks, &wg)
 	}
 
 	for _, repo := range repos {
@@ -99,19 +346,19 @@ func cloneAll(dirReposRoot string, repos []h.HosterRepository) {
 	wg.Wait()
 }
 
-func clone(dirReposRoot string, counter *int32, total int, tasks chan h.HosterRepository, wg *sync.WaitGroup) {
+func clone(dirReposRoot string, targets map[string]string, counter *int32, total int, tasks chan h.HosterRepository, wg *sync.WaitGroup) {
 	defer wg.Done()
 	for repo := range tasks {
+		dirTarget := targets[repo.PathWithNamespace]
 
-		var dirTarget string
-		switch config.Values.Options.Style {
-		case config.StyleFlat:
-			dirTarget = repo.Path
-		case config.StyleRecursive:
-			dirTarget = repo.PathWithNamespace
+		url := cloneUrl(repo)
+		if url == "" {
+			say.ProgressError(counter, total, nil, repo.PathWithNamespace, repo.WebUrl, "- No url for the %s transport", config.Values.Options.Transport)
+			continue
 		}
-
-		err := gitclient.Clone(dirReposRoot, dirTarget, repo.SshUrl)
+		options := cloneOptions()
+		options.ProjectId = repo.Id
+		err := gitclient.Clone(dirReposRoot, dirTarget, url, options)
 		if err != nil {
 			say.ProgressError(counter, total, err, repo.PathWithNamespace, repo.WebUrl, "- Unable to clone")
 		} else {
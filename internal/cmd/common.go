@@ -2,12 +2,20 @@ package cmd
 
 import (
 	"errors"
+	"fmt"
 	"io/fs"
 	"math"
 	"os"
 	"path"
 	"path/filepath"
+	"repo/internal/config"
+	"repo/internal/gitclient"
 	h "repo/internal/hoster"
+	_ "repo/internal/hoster/bitbucket" // register hoster
+	_ "repo/internal/hoster/gitea"     // register hoster
+	_ "repo/internal/hoster/github"    // register hoster
+	_ "repo/internal/hoster/gitlab"    // register hoster
+	"repo/internal/hoster/plain"
 	"repo/internal/model"
 	"repo/internal/say"
 	"repo/internal/util"
@@ -29,6 +37,35 @@ func handleFatalError(err error) {
 	}
 }
 
+// creates the hoster selected in the configuration
+func makeHoster() (h.Hoster, error) {
+	return h.MakeHosterNamed(config.Values.Options.Hoster)
+}
+
+// creates all configured hosters, local repositories are assigned to them by their origin remote
+func makeHosters() (h.Hosters, error) {
+	return h.MakeHosters()
+}
+
+// plain git hosters have no API to download the repo.yaml of the repositories
+func supportsManifests(hoster h.Hoster) bool {
+	_, isPlain := hoster.(*plain.Plain)
+	return !isPlain
+}
+
+// the hosters of the given repositories
+func usedHosters(hosters h.Hosters, gitDirs []model.RepoDir) (result h.Hosters) {
+	for _, hoster := range hosters {
+		for _, gd := range gitDirs {
+			if gd.Host == hoster.Host() {
+				result = append(result, hoster)
+				break
+			}
+		}
+	}
+	return
+}
+
 func validateConditions(conds ...cobra.PositionalArgs) cobra.PositionalArgs {
 	return func(cmd *cobra.Command, args []string) error {
 		for _, cond := range conds {
@@ -75,7 +112,7 @@ func validateArgGitDir(argIndex int, repoParent bool, repoRoot bool) cobra.Posit
 
 // check all directories recursivly for .git directory
 // collect them and return the array
-func collectGitDirs(root string, hoster h.Hoster) (result []model.RepoDir, err error) {
+func collectGitDirs(root string, hosters h.Hosters) (result []model.RepoDir, err error) {
 
 	ignored := []string{path.Join(root, dirArchived), path.Join(root, dirRemoved)}
 
@@ -88,12 +125,17 @@ func collectGitDirs(root string, hoster h.Hoster) (result []model.RepoDir, err e
 			return fs.SkipDir
 		}
 
-		if util.ExistsDir(path.Join(dir, ".git")) { // check if given path is git-repository
-			repo, err := model.MakeRepoDir(dir, hoster.Host())
+		bare := !util.ExistsDir(path.Join(dir, ".git")) && gitclient.IsBare(dir)
+		if util.ExistsDir(path.Join(dir, ".git")) || bare { // check if given path is git-repository
+			repo, err := model.MakeRepoDir(dir, hosters.Hosts())
 			if err != nil {
 				say.Verbose("Failed determine repository directory: %s", e)
 				return e
 			}
+			repo.Bare = bare
+			if bare {
+				repo.Name = strings.TrimSuffix(repo.Name, ".git") // mirrors are cloned into <name>.git
+			}
 			result = append(result, *repo)
 			return fs.SkipDir
 		}
@@ -104,8 +146,8 @@ func collectGitDirs(root string, hoster h.Hoster) (result []model.RepoDir, err e
 }
 
 // convenience function, would exit automatically on error or empty result
-func collectGitDirsHandled(dir string, hoster h.Hoster) []model.RepoDir {
-	gitDirs, err := collectGitDirs(dir, hoster)
+func collectGitDirsHandled(dir string, hosters h.Hosters) []model.RepoDir {
+	gitDirs, err := collectGitDirs(dir, hosters)
 	handleFatalError(err)
 
 	if len(gitDirs) == 0 {
@@ -114,6 +156,34 @@ func collectGitDirsHandled(dir string, hoster h.Hoster) []model.RepoDir {
 	return gitDirs
 }
 
+// adds the flag for the transport used by git to the command
+func addTransportFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("transport", "", config.TransportSsh, "Transport for git, either 'ssh' or 'https' (using the API-token via a credential helper).")
+}
+
+// validates the transport set by the flag or configuration
+func validateTransport() {
+	if transport := config.Values.Options.Transport; !slices.Contains(config.Transports, transport) {
+		handleFatalError(fmt.Errorf("invalid value for transport: %q (available: %s)", transport, config.Transports))
+	}
+}
+
+// adds the flag to only print the planned changes to the command. Not a persistent flag, as update, validate and serve have no dry-run.
+func addDryRunFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolP("dry-run", "", false, "Only print the planned changes, without changing the directories or the hoster")
+}
+
+// adds the flag for the boolean expression selecting the repositories to the command
+func addWhereFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("where", "", "", "Boolean expression selecting the repositories, eg. 'topic:library && !path:^legacy/ || (lang:go && starred)'.")
+}
+
+// adds the flags for the cached repository listing to the command
+func addCacheFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolP("refresh", "", false, "Ignore the cached repository listing and retrieve it from the hoster")
+	cmd.Flags().DurationP("max-age", "", 0, "Maximum age of the cached repository listing to be used, eg. 1h (0 disables the cache)")
+}
+
 func getParallelism(given int) int {
 	return int(math.Max(1, float64(given)))
 }
@@ -128,6 +198,14 @@ func getAbsoluteRepoRoot(repositoryArg string) string {
 	return abs
 }
 
+// link to the repository at the hoster, empty if the hoster is unknown
+func getWebUrl(host string, remotePath string) string {
+	if host == "" || remotePath == "" {
+		return ""
+	}
+	return "https://" + host + "/" + remotePath
+}
+
 func getRelativRepoDir(dirAbsRepoRoot string, dirAbsRepo string) string {
 	return strings.TrimPrefix(strings.TrimPrefix(dirAbsRepoRoot, dirAbsRepo), "/")
 }
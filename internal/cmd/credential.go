@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"repo/internal/config"
+	h "repo/internal/hoster"
+	"repo/internal/say"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(credentialCmd)
+}
+
+var credentialCmd = &cobra.Command{
+	Use:    "credential [operation]",
+	Short:  "Git credential helper providing the API-token of the configured hosters",
+	Long:   `Git credential helper providing the API-token of the configured hosters for https remotes. It is configured by clone for the https transport, only the 'get' operation is supported.`,
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if args[0] != "get" {
+			return // store and erase are left to other helpers
+		}
+		config.Init(cmd.Flags())
+
+		request := map[string]string{}
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			key, value, found := strings.Cut(scanner.Text(), "=")
+			if !found {
+				break
+			}
+			request[key] = value
+		}
+		if request["protocol"] != "https" {
+			return
+		}
+		for _, entry := range config.HosterEntries() {
+			if entry.ApiToken == "" {
+				continue
+			}
+			hoster, err := h.MakeHoster(entry)
+			if err != nil || !sameHttpsHost(hoster.Host(), request["host"]) {
+				continue
+			}
+			username := credentialUsername(entry)
+			if username == "" {
+				say.Error("The username has to be set for the https transport (hoster %s)", entry.Name)
+				return
+			}
+			fmt.Printf("username=%s\npassword=%s\n", username, entry.ApiToken)
+			return
+		}
+	},
+}
+
+// sameHttpsHost compares the hosts including their port, git passes "host:port" for non-default ports
+func sameHttpsHost(host string, requested string) bool {
+	withPort := func(value string) string {
+		if _, _, err := net.SplitHostPort(value); err != nil {
+			return value + ":443"
+		}
+		return value
+	}
+	return strings.EqualFold(withPort(host), withPort(requested))
+}
+
+// credentialUsername returns the configured username, or the one the hoster accepts for API-tokens
+func credentialUsername(entry config.Hoster) string {
+	if entry.Username != "" {
+		return entry.Username
+	}
+	switch entry.Type {
+	case "gitlab":
+		return "oauth2"
+	case "github":
+		return "x-access-token"
+	case "gitea":
+		return "oauth2" // the username is ignored for tokens
+	}
+	return "" // eg. bitbucket requires the username of the token owner
+}
+
+// credentialHelper returns the git configuration value to use this binary as credential helper
+func credentialHelper() string {
+	executable, err := os.Executable()
+	handleFatalError(err)
+	result := "!'" + executable + "'"
+	if config.ConfigFile != "" {
+		configFile, err := filepath.Abs(config.ConfigFile)
+		handleFatalError(err)
+		result = result + " -c '" + configFile + "'"
+	}
+	return result + " credential"
+}
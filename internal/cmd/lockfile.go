@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"repo/internal/config"
+	"repo/internal/gitclient"
+	"repo/internal/lockfile"
+	"repo/internal/say"
+	"repo/internal/util"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	color "github.com/logrusorgru/aurora/v4"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+	importCmd.Flags().IntP("parallelism", "p", 32, "How many process should run in parallel, 1 would be no parallelism.")
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export [root-dir]",
+	Short: "Writes a lockfile with the checked out state of the repositories to stdout",
+	Long: `Writes a lockfile with the relative path, origin remote, current branch and HEAD commit of every repository below the root-dir to stdout, eg. 'repow export . > workspace.lock'.
+Local changes and unpushed commits are not part of the lockfile, affected repositories are reported.`,
+	Args: validateConditions(cobra.ExactArgs(1), validateArgGitDir(0, false, true)),
+	Run: func(cmd *cobra.Command, args []string) {
+		config.Init(cmd.Flags())
+		dirReposRoot := getAbsoluteRepoRoot(args[0])
+
+		hosters, err := makeHosters()
+		handleFatalError(err)
+
+		var entries []lockfile.Entry
+		for _, gd := range collectGitDirsHandled(dirReposRoot, hosters) {
+			dirRelative := getRelativRepoDir(gd.Path, dirReposRoot)
+			entry := lockfile.Entry{
+				Path:   dirRelative,
+				Url:    gitclient.GetRemoteUrl(gd.Path),
+				Commit: gitclient.GetHeadCommit(gd.Path),
+				Bare:   gd.Bare,
+			}
+			if entry.Url == "" {
+				say.Error("Repository %s has no origin remote (skipping)", dirRelative)
+				continue
+			}
+			if branch := gitclient.GetCurrentBranch(gd.Path); branch != "HEAD" && branch != "-" {
+				entry.Branch = branch
+			}
+			if !gd.Bare && gitclient.IsDirty(gd.Path) {
+				say.Error("Repository %s has local changes, which are not part of the lockfile", dirRelative)
+			}
+			entries = append(entries, entry)
+		}
+
+		content, err := lockfile.Marshal(entries)
+		handleFatalError(err)
+		_, err = os.Stdout.Write(content)
+		handleFatalError(err)
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import [lockfile] [root-dir]",
+	Short: "Clones the missing repositories of a lockfile and checks out the recorded state",
+	Long: `Clones the missing repositories of a lockfile (written by export) into the root-dir and checks out the recorded branch and commit.
+Existing repositories are not changed, differing commits are reported.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		config.Init(cmd.Flags())
+		content, err := os.ReadFile(args[0])
+		handleFatalError(err)
+		lock, err := lockfile.Parse(content)
+		handleFatalError(err)
+
+		dirReposRoot := getAbsoluteRepoRoot(args[1])
+		handleFatalError(os.MkdirAll(dirReposRoot, 0755))
+		importRepositories(dirReposRoot, lock.Repositories)
+	},
+}
+
+type importCounters struct {
+	cloned    int32
+	unchanged int32
+	differing int32
+	failed    int32
+}
+
+func importRepositories(dirReposRoot string, entries []lockfile.Entry) {
+	counter := int32(0)
+	counters := importCounters{}
+	defer func(start time.Time) {
+		say.Plain("%s Finished, took %s (%d Cloned, %d Unchanged, %d Differing, %d Failed)",
+			say.Repow(), time.Since(start), color.Blue(counters.cloned).Bold(), color.Green(counters.unchanged).Bold(),
+			color.Yellow(counters.differing).Bold(), color.Red(counters.failed).Bold())
+	}(time.Now())
+
+	tasks := make(chan lockfile.Entry)
+	var wg sync.WaitGroup
+	for i := 0; i < getParallelism(config.Values.Options.Parallelism); i++ {
+		wg.Add(1)
+		go importRepository(dirReposRoot, &counter, len(entries), &counters, tasks, &wg)
+	}
+	for _, entry := range entries {
+		tasks <- entry
+	}
+	close(tasks)
+	wg.Wait()
+}
+
+func importRepository(dirReposRoot string, counter *int32, total int, counters *importCounters, tasks chan lockfile.Entry, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for entry := range tasks {
+		dirRepository := path.Join(dirReposRoot, entry.Path)
+		if util.ExistsDir(dirRepository) {
+			if commit := gitclient.GetHeadCommit(dirRepository); commit != entry.Commit {
+				say.ProgressWarn(counter, total, nil, entry.Path, "", "- Exists with commit %s instead of %s (not changed)", shortCommit(commit), shortCommit(entry.Commit))
+				atomic.AddInt32(&counters.differing, 1)
+			} else {
+				say.ProgressSuccess(counter, total, entry.Path, "", "")
+				atomic.AddInt32(&counters.unchanged, 1)
+			}
+			continue
+		}
+
+		if err := importClone(dirReposRoot, entry); err != nil {
+			removeEmptyParents(dirReposRoot, path.Dir(dirRepository))
+			say.ProgressError(counter, total, err, entry.Path, "", "- Unable to import")
+			atomic.AddInt32(&counters.failed, 1)
+			continue
+		}
+		say.ProgressGeneric(counter, total, color.Blue("↓").Bold().String(), entry.Path, "", "- %s", describeRef(entry))
+		atomic.AddInt32(&counters.cloned, 1)
+	}
+}
+
+// clones the repository and checks out the recorded commit, a failed checkout removes the clone again
+func importClone(dirReposRoot string, entry lockfile.Entry) error {
+	options := gitclient.CloneOptions{Mirror: entry.Bare}
+	if strings.HasPrefix(entry.Url, "https://") {
+		options.CredentialHelper = credentialHelper()
+	}
+	if err := gitclient.Clone(dirReposRoot, entry.Path, entry.Url, options); err != nil {
+		return fmt.Errorf("unable to clone %s: %w", entry.Url, err)
+	}
+	if entry.Bare || entry.Commit == "" {
+		return nil
+	}
+	dirRepository := path.Join(dirReposRoot, entry.Path)
+	if err := gitclient.Checkout(dirRepository, entry.Branch, entry.Commit); err != nil {
+		if e := os.RemoveAll(dirRepository); e != nil {
+			say.Verbose("Unable to remove %s: %s", dirRepository, e)
+		}
+		return err
+	}
+	return nil
+}
+
+func describeRef(entry lockfile.Entry) string {
+	if entry.Branch == "" {
+		return fmt.Sprintf("detached at %s", shortCommit(entry.Commit))
+	}
+	return fmt.Sprintf("%s at %s", entry.Branch, shortCommit(entry.Commit))
+}
+
+func shortCommit(commit string) string {
+	if commit == "" {
+		return "-"
+	}
+	return commit[:min(len(commit), 8)]
+}
@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"repo/internal/config"
+	"repo/internal/layout"
+	"repo/internal/model"
+	"repo/internal/say"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	color "github.com/logrusorgru/aurora/v4"
+	"github.com/spf13/cobra"
+)
+
+var migrateTo string
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.Flags().StringVarP(&migrateTo, "to", "", "", "Target style, either 'flat' or 'recursive'")
+	migrateCmd.Flags().StringP("template", "", "", "Path template for the target directory instead of the style")
+	addDryRunFlag(migrateCmd)
+}
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate-layout [root-dir]",
+	Short: "Moves the repositories into the layout of another style or path template",
+	Long: `Moves the repositories into the layout of another style or path template. The target directory is determined by the origin remote of each repository.
+Repositories without known remote, or with a target directory that is already used, are reported and not moved.`,
+	Args: validateConditions(cobra.ExactArgs(1), validateArgGitDir(0, false, true)),
+	Run: func(cmd *cobra.Command, args []string) {
+		config.WorkspaceDir = args[0]
+		config.Init(cmd.Flags())
+		dirReposRoot := getAbsoluteRepoRoot(args[0])
+
+		stylesAvailable := []string{config.StyleFlat, config.StyleRecursive}
+		switch {
+		case migrateTo != "" && cmd.Flags().Changed("template"):
+			handleFatalError(errors.New("either --to or --template can be passed, not both"))
+		case migrateTo != "" && !slices.Contains(stylesAvailable, migrateTo):
+			handleFatalError(fmt.Errorf("invalid value for --to: %q (available: %s)", migrateTo, stylesAvailable))
+		case migrateTo != "":
+			// the style takes precedence over a path template of the configuration or workspace file
+			config.Values.Options.Style = migrateTo
+			config.Values.Options.Template = ""
+		case config.Values.Options.Template == "":
+			handleFatalError(fmt.Errorf("either --to (%s) or --template has to be passed", stylesAvailable))
+		default:
+			_, err := targetTemplate()
+			handleFatalError(err)
+		}
+		config.Values.Options.Mirror = false // determined per repository
+
+		hosters, err := makeHosters()
+		handleFatalError(err)
+
+		gitDirs := collectGitDirsHandled(dirReposRoot, hosters)
+		if migrateLayout(dirReposRoot, planMigration(dirReposRoot, gitDirs)) && !config.Values.Options.DryRun {
+			// later runs of clone and cleanup have to use the new layout
+			updated, err := config.WriteWorkspaceLayout(dirReposRoot, config.Values.Options.Style, config.Values.Options.Template)
+			handleFatalError(err)
+			if updated {
+				say.InfoLn("Workspace file %s updated to the new layout", config.WorkspaceFilename)
+			}
+		}
+	},
+}
+
+type migration struct {
+	source string // relative directory of the repository
+	target string // relative target directory, empty if the repository is not moved
+	err    error  // reason the repository can not be moved
+}
+
+// planMigration determines the target directories, the moves are checked against each other and the existing directories
+func planMigration(dirReposRoot string, gitDirs []model.RepoDir) []migration {
+	var result []migration
+	sources := map[string]bool{}
+	for _, gd := range gitDirs {
+		sources[getRelativRepoDir(gd.Path, dirReposRoot)] = true
+	}
+
+	targets := map[string]string{}
+	for _, gd := range gitDirs {
+		m := migration{source: getRelativRepoDir(gd.Path, dirReposRoot)}
+		result = append(result, m)
+		if gd.RemotePath == "" {
+			continue
+		}
+		target, err := getRepoDirTargetDir(gd)
+		if err != nil {
+			continue
+		}
+		targets[m.source] = target
+	}
+	collisions := layout.Collisions(targets)
+
+	for i, m := range result {
+		target, exists := targets[m.source]
+		switch {
+		case !exists:
+			result[i].err = errors.New("unable to determine target directory, unknown remote or template values")
+		case target == m.source:
+		case len(collisions[target]) > 0:
+			result[i].err = fmt.Errorf("target directory %s is used by multiple repositories: %s", target, strings.Join(collisions[target], ", "))
+		case blockedTarget(dirReposRoot, target, sources):
+			result[i].err = fmt.Errorf("target directory %s already exists or is within another repository", target)
+		default:
+			result[i].target = target
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].source < result[j].source
+	})
+	return result
+}
+
+// the target is blocked, if it exists or one of its parents is a repository
+func blockedTarget(dirReposRoot string, target string, sources map[string]bool) bool {
+	if _, err := os.Stat(path.Join(dirReposRoot, target)); err == nil {
+		return true
+	}
+	for dir := path.Dir(target); dir != "."; dir = path.Dir(dir) {
+		if sources[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+// migrateLayout moves the repositories, returns false if any repository could not be moved
+func migrateLayout(dirReposRoot string, migrations []migration) bool {
+	counter := int32(0)
+	var moved, unchanged, skipped int
+	defer func(start time.Time) {
+		say.Plain("%s Finished, took %s (%d Moved, %d Unchanged, %d Skipped)",
+			say.Repow(), time.Since(start), color.Blue(moved).Bold(), color.Green(unchanged).Bold(), color.Yellow(skipped).Bold())
+	}(time.Now())
+
+	total := len(migrations)
+	for _, m := range migrations {
+		switch {
+		case m.err != nil:
+			say.ProgressWarn(&counter, total, m.err, m.source, "", "- Not moved")
+			skipped++
+		case m.target == "":
+			say.Verbose("Repository %s is already in place", m.source)
+			unchanged++
+		case config.Values.Options.DryRun:
+			say.ProgressGeneric(&counter, total, color.Blue("→").Bold().String(), m.source, "", "- %s [dry-run]", m.target)
+			moved++
+		default:
+			err := moveRepository(path.Join(dirReposRoot, m.source), path.Join(dirReposRoot, m.target))
+			if err != nil {
+				say.ProgressError(&counter, total, err, m.source, "", "- Unable to move to %s", m.target)
+				skipped++
+				continue
+			}
+			removeEmptyParents(dirReposRoot, path.Dir(path.Join(dirReposRoot, m.source)))
+			say.ProgressGeneric(&counter, total, color.Blue("→").Bold().String(), m.source, "", "- %s", m.target)
+			moved++
+		}
+	}
+	return skipped == 0
+}
+
+// removes the directories left empty by a move, up to the root directory
+func removeEmptyParents(dirReposRoot string, dir string) {
+	for dir != dirReposRoot && strings.HasPrefix(dir, dirReposRoot) {
+		if os.Remove(dir) != nil {
+			return // not empty
+		}
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"repo/internal/cache"
+	"repo/internal/config"
+	"repo/internal/gitclient"
+	h "repo/internal/hoster"
+	"repo/internal/model"
+	"repo/internal/say"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	color "github.com/logrusorgru/aurora/v4"
+	"github.com/spf13/cobra"
+)
+
+var relocateQuiet bool
+var relocateParallelism int
+
+func init() {
+	rootCmd.AddCommand(relocateCmd)
+	relocateCmd.Flags().BoolVarP(&relocateQuiet, "quiet", "q", false, "Output only affected repositories")
+	relocateCmd.Flags().IntVarP(&relocateParallelism, "parallelism", "p", 32, "How many process should run in parallel, 1 would be no parallelism.")
+	addDryRunFlag(relocateCmd)
+}
+
+var relocateCmd = &cobra.Command{
+	Use:   "relocate [root-dir]",
+	Short: "Follows renamed and transferred repositories with the origin remote and the directory",
+	Long: `Determines the current path of renamed or transferred repositories at the hoster and updates the origin remote.
+The directory is moved as well, if it is located where clone would have put it with the old path (eg. the group path for the 'recursive' style).`,
+	Args: validateConditions(cobra.ExactArgs(1), validateArgGitDir(0, false, true)),
+	Run: func(cmd *cobra.Command, args []string) {
+		config.WorkspaceDir = args[0]
+		config.Init(cmd.Flags())
+		dirReposRoot := getAbsoluteRepoRoot(args[0])
+		if config.Values.Options.Template != "" {
+			_, err := targetTemplate()
+			handleFatalError(err)
+		}
+		config.Values.Options.Mirror = false // determined per repository
+
+		hosters, err := makeHosters()
+		handleFatalError(err)
+
+		gitDirs := collectGitDirsHandled(dirReposRoot, hosters)
+		relocateRepositories(dirReposRoot, gitDirs, hosters, listedIds(hosters, gitDirs))
+	},
+}
+
+type relocateCounters struct {
+	ok        int32
+	skipped   int32
+	relocated int32
+}
+
+// listedIds returns the IDs of the repositories from the cached listings regardless of their age, keyed by host and
+// lowercase path. Older listings still contain the previous paths of renamed repositories.
+func listedIds(hosters h.Hosters, gitDirs []model.RepoDir) map[string]map[string]int {
+	result := map[string]map[string]int{}
+	for _, hoster := range usedHosters(hosters, gitDirs) {
+		result[hoster.Host()] = map[string]int{}
+		if listing, exists := cache.Load(hoster.Host(), h.RequestOptions{}, math.MaxInt64); exists {
+			for _, repo := range listing.Repositories {
+				result[hoster.Host()][strings.ToLower(repo.PathWithNamespace)] = repo.Id
+			}
+		}
+	}
+	return result
+}
+
+func relocateRepositories(dirReposRoot string, dirs []model.RepoDir, hosters h.Hosters, ids map[string]map[string]int) {
+	counter := int32(0)
+	counters := relocateCounters{}
+
+	defer func(start time.Time) {
+		say.Plain("%s Finished, took %s (%d Ok, %d Skipped, %d Relocated)",
+			say.Repow(), time.Since(start), color.Green(counters.ok).Bold(), color.Yellow(counters.skipped).Bold(), color.Blue(counters.relocated).Bold())
+	}(time.Now())
+
+	tasks := make(chan model.RepoDir)
+	var wg sync.WaitGroup
+	var moving sync.Mutex
+	for i := 0; i < getParallelism(config.Values.Options.Parallelism); i++ {
+		wg.Add(1)
+		go relocateDir(dirReposRoot, hosters, ids, &counter, len(dirs), &counters, &moving, tasks, &wg)
+	}
+
+	for _, dirRepository := range dirs {
+		tasks <- dirRepository
+	}
+	close(tasks)
+	wg.Wait()
+}
+
+func relocateDir(dirReposRoot string, hosters h.Hosters, ids map[string]map[string]int, counter *int32, total int, counters *relocateCounters, moving *sync.Mutex, tasks chan model.RepoDir, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for dirRepository := range tasks {
+		dirRepoRelative := getRelativRepoDir(dirRepository.Path, dirReposRoot)
+		remotePath := dirRepository.RemotePath
+		webUrl := getWebUrl(dirRepository.Host, remotePath)
+
+		locator, ok := hosters.ByHost(dirRepository.Host).(h.LocatingHoster)
+		if remotePath == "" || !ok {
+			say.ProgressWarn(counter, total, nil, dirRepoRelative, webUrl, "- Unable to locate repositories for git remote (skipping)")
+			atomic.AddInt32(&counters.skipped, 1)
+			continue
+		}
+
+		// the ID stored when cloning, or the one of the cached listing, is not affected by reused paths
+		id := gitclient.GetProjectId(dirRepository.Path)
+		if id == 0 {
+			id = ids[dirRepository.Host][strings.ToLower(remotePath)]
+		}
+		repo, err := locator.Locate(remotePath, id)
+		if err != nil {
+			say.ProgressWarn(counter, total, err, dirRepoRelative, webUrl, "- Unable to locate repository (skipping)")
+			atomic.AddInt32(&counters.skipped, 1)
+			continue
+		}
+		if strings.EqualFold(repo.PathWithNamespace, remotePath) {
+			if !relocateQuiet {
+				say.ProgressSuccess(counter, total, dirRepoRelative, webUrl, "")
+			}
+			atomic.AddInt32(&counters.ok, 1)
+			continue
+		}
+
+		originUrl := gitclient.GetRemoteUrl(dirRepository.Path)
+		relocatedUrl := relocateUrl(originUrl, remotePath, repo.PathWithNamespace, cloneUrl(*repo))
+
+		// only moved, if the directory is where clone would have put the repository with the old path
+		dirTarget := dirRepoRelative
+		current := dirRepository
+		current.RemotePath, current.Name = repo.PathWithNamespace, repo.Name
+		if dirPrevious, err := getRepoDirTargetDir(dirRepository); err == nil && dirPrevious == dirRepoRelative {
+			if dirCurrent, err := getRepoDirTargetDir(current); err == nil {
+				dirTarget = dirCurrent
+			} else {
+				say.Verbose("Unable to determine target directory for %s: %s", repo.PathWithNamespace, err)
+			}
+		}
+
+		message := "- " + remotePath + " → " + repo.PathWithNamespace
+		if dirTarget != dirRepoRelative {
+			message = message + " (moving to " + dirTarget + ")"
+		}
+		if config.Values.Options.DryRun {
+			say.ProgressGeneric(counter, total, color.Blue("→").Bold().String(), dirRepoRelative, repo.WebUrl, "%s [dry-run]", message)
+			atomic.AddInt32(&counters.relocated, 1)
+			continue
+		}
+
+		say.Verbose("Updating origin of %s from %s to %s", dirRepoRelative, originUrl, relocatedUrl)
+		if err := gitclient.SetRemoteUrl(dirRepository.Path, relocatedUrl); err != nil {
+			say.ProgressError(counter, total, err, dirRepoRelative, webUrl, "- Unable to update origin")
+			atomic.AddInt32(&counters.skipped, 1)
+			continue
+		}
+		if dirTarget != dirRepoRelative {
+			moving.Lock()
+			err = moveRepository(dirRepository.Path, path.Join(dirReposRoot, dirTarget))
+			moving.Unlock()
+			if err != nil {
+				say.ProgressError(counter, total, err, dirRepoRelative, repo.WebUrl, "%s - Origin updated, unable to move", message)
+				atomic.AddInt32(&counters.skipped, 1)
+				continue
+			}
+		}
+		say.ProgressGeneric(counter, total, color.Blue("→").Bold().String(), dirRepoRelative, repo.WebUrl, "%s", message)
+		atomic.AddInt32(&counters.relocated, 1)
+	}
+}
+
+// relocateUrl replaces the old path in the origin url, so the protocol and credentials are kept
+func relocateUrl(originUrl string, oldPath string, newPath string, fallback string) string {
+	index := strings.LastIndex(originUrl, oldPath)
+	if index < 0 {
+		return fallback
+	}
+	return originUrl[:index] + newPath + originUrl[index+len(oldPath):]
+}
+
+// moves the repository directory, the target must not exist yet
+func moveRepository(dirAbsSource string, dirAbsTarget string) error {
+	if _, err := os.Stat(dirAbsTarget); err == nil {
+		return errors.New("directory in target location already exists")
+	}
+	if err := os.MkdirAll(filepath.Dir(dirAbsTarget), 0755); err != nil {
+		return err
+	}
+	say.Verbose("Moving %s to %s", dirAbsSource, dirAbsTarget)
+	return os.Rename(dirAbsSource, dirAbsTarget)
+}
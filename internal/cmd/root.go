@@ -15,10 +15,12 @@ var rootCmd = &cobra.Command{
 }
 
 var VersionPassed string
+var hosterName string
 
 func Execute() {
 	rootCmd.PersistentFlags().BoolVarP(&say.VerboseEnabled, "verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().StringVarP(&config.ConfigFile, "configfile", "c", "", "custom config-file location (default "+config.DefaultConfigFile()+")")
+	rootCmd.PersistentFlags().StringVarP(&hosterName, "hoster", "", "", "name of the configured hoster to use (default from configuration)")
 	err := rootCmd.Execute()
 	handleFatalError(err)
 }
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"repo/internal/cache"
+	"repo/internal/config"
+	h "repo/internal/hoster"
+	"repo/internal/model"
+	"repo/internal/say"
+	"repo/internal/selector"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// parses the --where expression, nil if none is given
+func parseWhere(expression string) *selector.Selector {
+	if expression == "" {
+		return nil
+	}
+	result, err := selector.Parse(expression)
+	handleFatalError(err)
+	return result
+}
+
+// starred repositories of the hoster, keyed by the lowercase path
+func starredPaths(hoster h.Hoster) map[string]bool {
+	result := map[string]bool{}
+	for _, repo := range cache.Repositories(hoster, h.RequestOptions{Starred: true}, config.Values.Options.MaxAge, config.Values.Options.Refresh) {
+		result[strings.ToLower(repo.PathWithNamespace)] = true
+	}
+	return result
+}
+
+// selectListed keeps the listed repositories matching the --where expression. The starred repositories and the
+// repo.yaml of the repositories are only retrieved, if the expression requires them.
+func selectListed(hoster h.Hoster, where *selector.Selector, repos []h.HosterRepository) []h.HosterRepository {
+	starred := map[string]bool{}
+	if where.Uses("starred") {
+		starred = starredPaths(hoster)
+	}
+	manifests := map[string]*model.RepoYaml{}
+	if where.Uses(selector.ManifestKeys...) {
+		manifests = cache.Manifests(hoster, repos, config.Values.Options.MaxAge, config.Values.Options.Refresh, getParallelism(config.Values.Options.Parallelism))
+	}
+
+	var result []h.HosterRepository
+	for _, repo := range repos {
+		candidate := selector.Candidate{
+			HosterRepository: repo,
+			Starred:          starred[strings.ToLower(repo.PathWithNamespace)],
+			RepoYaml:         manifests[repo.PathWithNamespace],
+		}
+		if where.Matches(candidate) {
+			result = append(result, repo)
+		}
+	}
+	say.InfoLn("%d repositories matching --where (%d filtered)", len(result), len(repos)-len(result))
+	return result
+}
+
+// adds the flags selecting local repositories to the command, commands using -e otherwise pass an empty excludeShort
+func addSelectionFlags(cmd *cobra.Command, excludeShort string) {
+	cmd.Flags().StringSliceP("topic", "t", nil, "Topics of the repo.yaml to be filtered. Multiple topics are possible (and).")
+	cmd.Flags().StringSliceP("include", "i", nil, "Regex-pattern that needs to be matched for the remote path. Multiple patterns are possible (or).")
+	cmd.Flags().StringSliceP("exclude", excludeShort, nil, "Regex-pattern not to be matched for the remote path. Multiple patterns are possible (and).")
+	addWhereFlag(cmd)
+}
+
+// localSelection reads the selection of local repositories from the flags of the command only. The filters of the
+// configuration and the workspace file are meant for the listing of the hoster when cloning.
+func localSelection(cmd *cobra.Command) (h.RequestOptions, *selector.Selector) {
+	var options h.RequestOptions
+	var err error
+	options.Topics, err = cmd.Flags().GetStringSlice("topic")
+	handleFatalError(err)
+	options.IncludePatterns, err = cmd.Flags().GetStringSlice("include")
+	handleFatalError(err)
+	options.ExcludePatterns, err = cmd.Flags().GetStringSlice("exclude")
+	handleFatalError(err)
+	where, err := cmd.Flags().GetString("where")
+	handleFatalError(err)
+	return options, parseWhere(where)
+}
+
+// selectGitDirs keeps the local repositories matching the topics, patterns and --where expression of the command.
+// The path is the remote path (or the relative directory if the remote is unknown), the topics are the ones of the
+// local repo.yaml. If the expression requires attributes listed by the hoster, like starred or archived, the (cached)
+// listing of the hosters is used.
+func selectGitDirs(cmd *cobra.Command, dirReposRoot string, gitDirs []model.RepoDir, hosters h.Hosters) []model.RepoDir {
+	options, where := localSelection(cmd)
+	if where == nil && len(options.Topics) == 0 && len(options.IncludePatterns) == 0 && len(options.ExcludePatterns) == 0 {
+		return gitDirs
+	}
+
+	listed := map[string]map[string]h.HosterRepository{}
+	starred := map[string]map[string]bool{}
+	if where != nil && where.Uses(selector.ListingKeys...) {
+		for _, hoster := range usedHosters(hosters, gitDirs) {
+			listed[hoster.Host()] = map[string]h.HosterRepository{}
+			for _, repo := range cache.Repositories(hoster, h.RequestOptions{Archived: true}, config.Values.Options.MaxAge, config.Values.Options.Refresh) {
+				listed[hoster.Host()][strings.ToLower(repo.PathWithNamespace)] = repo
+			}
+			if where.Uses("starred") {
+				starred[hoster.Host()] = starredPaths(hoster)
+			}
+		}
+	}
+
+	var result []model.RepoDir
+	for _, gd := range gitDirs {
+		candidate := localCandidate(dirReposRoot, gd, listed, starred)
+		if h.Matches(options, candidate.PathWithNamespace, candidate.Topics) && (where == nil || where.Matches(candidate)) {
+			result = append(result, gd)
+		}
+	}
+	say.InfoLn("%d repositories selected (%d filtered)", len(result), len(gitDirs)-len(result))
+	return result
+}
+
+// the local repository as candidate for the selection, enriched with the listed attributes if known
+func localCandidate(dirReposRoot string, gd model.RepoDir, listed map[string]map[string]h.HosterRepository, starred map[string]map[string]bool) selector.Candidate {
+	remotePath := strings.ToLower(gd.RemotePath)
+	repo, exists := listed[gd.Host][remotePath]
+	if !exists {
+		repo = h.HosterRepository{PathWithNamespace: gd.RemotePath}
+	}
+	if repo.PathWithNamespace == "" {
+		repo.PathWithNamespace = getRelativRepoDir(gd.Path, dirReposRoot)
+	}
+
+	result := selector.Candidate{HosterRepository: repo, Starred: starred[gd.Host][remotePath]}
+	if gd.RepoYaml != nil && gd.RepoYamlValid {
+		result.RepoYaml = gd.RepoYaml
+		result.Topics = slices.Clone(repo.Topics)
+		for _, topic := range h.ManifestTopics(gd.RepoYaml) {
+			if !slices.Contains(result.Topics, topic) {
+				result.Topics = append(result.Topics, topic)
+			}
+		}
+	}
+	return result
+}
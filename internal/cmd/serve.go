@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"repo/internal/config"
 	h "repo/internal/hoster"
+	"repo/internal/hoster/github"
 	"repo/internal/hoster/gitlab"
 	"repo/internal/model"
 	"repo/internal/notification"
@@ -31,6 +32,7 @@ var serveCmd = &cobra.Command{
 }
 
 func startServer() {
+	handleFatalError(config.ValidateHosters())
 	initServer()
 
 	// Can act as healthcheck/readiness
@@ -39,30 +41,60 @@ func startServer() {
 		fmt.Fprintf(w, "pong")
 	})
 
-	http.HandleFunc("/webhook/gitlab", func(w http.ResponseWriter, r *http.Request) {
-		hoster, webhook, err := gitlab.HandleWebhookGitlab(w, r)
-		if err != nil {
-			w.Write([]byte(err.Error()))
-			return
-		}
-		if hoster == nil || webhook == nil {
-			say.Verbose("hoster or webhook empty")
-			return
-		}
-		// check default branch
-		if "refs/heads/"+webhook.Project.DefaultBranch != webhook.Ref {
-			w.Write([]byte(fmt.Sprintf("Skipping non-default branch %s for %s", webhook.Project.DefaultBranch, webhook.Project.Name)))
-			return
-		}
-
-		go processWebhook(w, r, hoster, webhook.Project.Name, webhook.Project.PathWithNamespace, webhook.Ref)
-	})
+	for _, entry := range config.HosterEntries() {
+		registerWebhook(entry)
+	}
 
 	beforeServer()
 
 	log.Fatal(http.ListenAndServe(":"+strconv.Itoa(config.Values.Server.Port), nil))
 }
 
+// registers the webhook endpoint for the hoster instance at /webhook/<name>
+func registerWebhook(entry config.Hoster) {
+	pattern := "/webhook/" + entry.Name
+	switch entry.Type {
+	case gitlab.HosterType:
+		http.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			hoster, webhook, err := gitlab.HandleWebhookGitlab(w, r, entry)
+			if err != nil {
+				w.Write([]byte(err.Error()))
+				return
+			}
+			if hoster == nil || webhook == nil {
+				say.Verbose("hoster or webhook empty")
+				return
+			}
+			handlePush(w, r, hoster, webhook.Project.Name, webhook.Project.PathWithNamespace, webhook.Project.DefaultBranch, webhook.Ref)
+		})
+	case github.HosterType:
+		http.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			hoster, webhook, err := github.HandleWebhookGithub(w, r, entry)
+			if err != nil {
+				w.Write([]byte(err.Error()))
+				return
+			}
+			if hoster == nil || webhook == nil {
+				say.Verbose("hoster or webhook empty")
+				return
+			}
+			handlePush(w, r, hoster, webhook.Project.Name, webhook.Project.PathWithNamespace, webhook.Project.DefaultBranch, webhook.Ref)
+		})
+	default:
+		say.Verbose("No webhook available for hoster %s (%s)", entry.Name, entry.Type)
+	}
+}
+
+func handlePush(w http.ResponseWriter, r *http.Request, hoster h.Hoster, name string, remotePath string, defaultBranch string, ref string) {
+	// check default branch
+	if "refs/heads/"+defaultBranch != ref {
+		w.Write([]byte(fmt.Sprintf("Skipping non-default branch %s for %s", defaultBranch, name)))
+		return
+	}
+
+	go processWebhook(w, r, hoster, name, remotePath, ref)
+}
+
 func initServer() {
 	say.InfoLn("Starting repow %s server...", say.Repow())
 }
@@ -4,15 +4,17 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"repo/internal/cache"
 	"repo/internal/config"
 	"repo/internal/gitclient"
-	"repo/internal/hoster/gitlab"
+	h "repo/internal/hoster"
 	"repo/internal/model"
 	"repo/internal/say"
 	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/logrusorgru/aurora/v4"
@@ -25,6 +27,12 @@ var updateParallelism int
 func init() {
 	rootCmd.AddCommand(updateCmd)
 	updateCmd.Flags().BoolVarP(&updateQuiet, "quiet", "q", false, "Output only affected repositories")
+	addTransportFlag(updateCmd)
+	addSelectionFlags(updateCmd, "e")
+	addCacheFlags(updateCmd)
+	updateCmd.Flags().StringP("strategy", "", gitclient.PullFFOnly, "Strategy for pulling, either 'ff-only', 'rebase' (local commits onto the remote changes) or 'merge'.")
+	updateCmd.Flags().BoolP("autostash", "", false, "Stash local changes before pulling and apply them afterwards")
+	updateCmd.Flags().BoolP("default-branch", "", false, "Switch clean repositories on a merged or stale branch to the default branch when pulling")
 	updateCmd.Flags().IntVarP(&updateParallelism, "parallelism", "p", 32, "How many process should run in parallel, 1 would be no parallelism.")
 }
 
@@ -36,33 +44,64 @@ var updateCmd = &cobra.Command{
 Mode can be one of:
   check - Outputs the current state of the local repositories
   fetch - Fetches remote changes and outputs the changes
-  pull  - Fetches remote changes, merges them (if fast-forward is possible) and outputs the changes`,
+  pull  - Fetches remote changes, integrates them with the --strategy (fast-forward only by default) and outputs the changes.
+          Conflicting pulls are aborted and the previous state is restored.
+          With --default-branch clean repositories on a merged branch, or a branch gone at the origin, are switched
+          to the default branch of the origin before.
+  unshallow - Fetches the complete history of shallow clones
+  mirror - Updates all references of bare repositories (eg. cloned with --mirror), other repositories are skipped`,
 	Args: validateConditions(cobra.ExactArgs(2), validateArgGitDir(1, false, true)),
 	Run: func(cmd *cobra.Command, args []string) {
+		config.WorkspaceDir = args[1]
 		config.Init(cmd.Flags())
-		defer say.Timer(time.Now())
-		modesAvailable := []string{"check", "fetch", "pull"}
+		modesAvailable := []string{"check", "fetch", "pull", "unshallow", "mirror"}
 
 		mode := args[0]
-		hoster, err := gitlab.MakeHoster()
+		counters := &pullCounters{}
+		defer func(start time.Time) {
+			switch mode {
+			case "pull":
+				say.Plain("%s Finished, took %s (%d Updated, %d Rebased, %d Merged, %d Conflicts, %d Failed)",
+					say.Repow(), time.Since(start), aurora.Green(counters.updated).Bold(), aurora.Blue(counters.rebased).Bold(),
+					aurora.Cyan(counters.merged).Bold(), aurora.Yellow(counters.conflicts).Bold(), aurora.Red(counters.failed).Bold())
+			case "unshallow":
+				say.Plain("%s Finished, took %s (%d Updated, %d Failed)",
+					say.Repow(), time.Since(start), aurora.Green(counters.updated).Bold(), aurora.Red(counters.failed).Bold())
+			default:
+				say.Timer(start)
+			}
+		}(time.Now())
+		hosters, err := makeHosters()
 		handleFatalError(err)
 
 		if !slices.Contains(modesAvailable, mode) {
 			handleFatalError(errors.New(fmt.Sprintf("mode has to be one of: %s", modesAvailable)))
 		}
 
+		if config.Values.Options.DefaultBranch && mode != "pull" {
+			handleFatalError(errors.New("--default-branch is only supported by the pull mode"))
+		}
+		validateTransport()
+		if strategy := config.Values.Options.Strategy; !slices.Contains(gitclient.PullStrategies, strategy) {
+			handleFatalError(fmt.Errorf("invalid value for strategy: %q (available: %s)", strategy, gitclient.PullStrategies))
+		}
+
 		dirReposRoot := getAbsoluteRepoRoot(args[1])
-		gitDirs := collectGitDirsHandled(dirReposRoot, hoster)
+		gitDirs := selectGitDirs(cmd, dirReposRoot, collectGitDirsHandled(dirReposRoot, hosters), hosters)
 
-		if mode == "fetch" || mode == "pull" {
-			gitclient.PrepareSsh(hoster.Host(), config.Values.Gitlab.SSHUser, config.Values.Gitlab.SSHPort)
+		if mode != "check" && config.Values.Options.Transport == config.TransportSsh {
+			for _, hoster := range usedHosters(hosters, gitDirs) {
+				sshUser, sshPort := hoster.SshAccess()
+				gitclient.PrepareSsh(hoster.Host(), sshUser, sshPort)
+			}
 		}
 
 		tasks := make(chan *StateContext)
 		var wg sync.WaitGroup
+		branches := &defaultBranches{hosters: hosters, listed: map[string]map[string]string{}}
 		for i := 0; i < getParallelism(config.Values.Options.Parallelism); i++ {
 			wg.Add(1)
-			go processRepository(mode, tasks, &wg)
+			go processRepository(mode, branches, counters, tasks, &wg)
 		}
 		counter := int32(0)
 		for _, gd := range gitDirs {
@@ -71,15 +110,12 @@ Mode can be one of:
 			rdIntermediate = gd
 			dirRelative := getRelativRepoDir(gd.Path, dirReposRoot)
 
-			remotePath := model.DetermineRemotePath(gd.Path, hoster.Host())
-			webUrl := "https://" + hoster.Host() + "/" + remotePath
-
 			tasks <- &StateContext{
 				total:       len(gitDirs),
 				counter:     &counter,
 				repo:        &rdIntermediate,
 				dirRelative: dirRelative,
-				webUrl:      webUrl,
+				webUrl:      getWebUrl(gd.Host, gd.RemotePath),
 			}
 		}
 
@@ -94,6 +130,7 @@ const (
 	clean State = iota
 	dirty
 	failed
+	updated // the repository itself has been updated, eg. unshallowed
 )
 
 type StateContext struct {
@@ -107,22 +144,71 @@ type StateContext struct {
 	behind      int
 	message     string
 	webUrl      string
+	pulled      bool                 // pulling has been tried, with the result
+	result      gitclient.PullResult // result of pulling
+}
+
+// pullCounters are the results of the pull and unshallow modes for the summary
+type pullCounters struct {
+	updated   int32
+	rebased   int32
+	merged    int32
+	conflicts int32
+	failed    int32
 }
 
-func processRepository(mode string, tasks chan *StateContext, wg *sync.WaitGroup) {
+func (c *pullCounters) count(ctx *StateContext) {
+	switch {
+	case ctx.state == failed && ctx.pulled && ctx.result == gitclient.PullConflict:
+		atomic.AddInt32(&c.conflicts, 1)
+	case ctx.state == failed:
+		atomic.AddInt32(&c.failed, 1)
+	case ctx.state == updated:
+		atomic.AddInt32(&c.updated, 1)
+	case !ctx.pulled:
+	case ctx.result == gitclient.PullUpdated:
+		atomic.AddInt32(&c.updated, 1)
+	case ctx.result == gitclient.PullRebased:
+		atomic.AddInt32(&c.rebased, 1)
+	case ctx.result == gitclient.PullMerged:
+		atomic.AddInt32(&c.merged, 1)
+	}
+}
+
+func processRepository(mode string, branches *defaultBranches, counters *pullCounters, tasks chan *StateContext, wg *sync.WaitGroup) {
 	defer wg.Done()
 	for ctx := range tasks {
 		ctx.ref = gitclient.GetCurrentBranch(ctx.repo.Path)
+		if ctx.repo.Bare != (mode == "mirror") {
+			// bare repositories have no working tree to be updated, mirrors are only updated with their mode
+			ctx.state = clean
+			printContext(ctx)
+			continue
+		}
 		switch mode {
 		case "check":
 			updateCheck(ctx)
 		case "fetch":
 			updateFetch(ctx)
 		case "pull":
+			defaultBranch, defaultCommit := "", ""
+			if config.Values.Options.DefaultBranch {
+				// the commit before fetching detects branches merged by the fetched changes
+				defaultBranch = getDefaultBranch(ctx, branches)
+				defaultCommit = gitclient.GetCommit(ctx.repo.Path, "refs/remotes/origin/"+defaultBranch)
+			}
 			updateFetch(ctx)
-			if ctx.state != failed && ctx.state != clean {
-				updatePull(ctx)
+			if ctx.state == failed || defaultBranch == "" || !updateDefaultBranch(ctx, defaultBranch, defaultCommit) {
+				if ctx.state != failed && ctx.state != clean {
+					updatePull(ctx)
+				}
 			}
+			counters.count(ctx)
+		case "unshallow":
+			updateUnshallow(ctx)
+			counters.count(ctx)
+		case "mirror":
+			updateMirror(ctx)
 		}
 		printContext(ctx)
 	}
@@ -138,7 +224,11 @@ func updateCheck(ctx *StateContext) {
 }
 
 func updateFetch(ctx *StateContext) {
-	fetched := gitclient.Fetch(ctx.repo.Path)
+	fetch := gitclient.Fetch
+	if config.Values.Options.DefaultBranch {
+		fetch = gitclient.FetchPrune // detects branches removed at the origin
+	}
+	fetched := fetch(ctx.repo.Path)
 	if !fetched {
 		ctx.state = failed
 		ctx.message = "Could not be fetched"
@@ -165,13 +255,146 @@ func updateFetch(ctx *StateContext) {
 	return
 }
 
+// updatePull integrates the remote changes with the strategy, conflicts are aborted and restored by the gitclient
 func updatePull(ctx *StateContext) {
-	success := gitclient.MergeFF(ctx.repo.Path)
-	if !success {
-		ctx.message = "Can not be merged, conflicting changes"
+	repoDir := ctx.repo.Path
+	var err error
+	ctx.pulled = true
+	ctx.result, err = gitclient.Pull(repoDir, "origin/"+ctx.ref, config.Values.Options.Strategy, config.Values.Options.Autostash)
+	switch ctx.result {
+	case gitclient.PullUnchanged:
+		return
+	case gitclient.PullConflict:
+		ctx.state = failed
+		ctx.message = "Conflict, aborted and restored"
+		if err != nil {
+			ctx.message = fmt.Sprintf("Conflict, unable to restore the previous state: %s", err)
+		}
+		return
+	case gitclient.PullFailed:
+		ctx.state = failed
+		ctx.message = fmt.Sprintf("Can not be pulled, %s", err)
+		return
+	}
+	ctx.message = gitclient.GetChanges(repoDir, ctx.behind)
+	switch ctx.result {
+	case gitclient.PullRebased:
+		ctx.message = "Rebased with local commits\n" + ctx.message
+	case gitclient.PullMerged:
+		ctx.message = "Merged with local commits\n" + ctx.message
+	}
+}
+
+// the default branch of the origin as known by origin/HEAD, or as listed by the hoster
+func getDefaultBranch(ctx *StateContext, branches *defaultBranches) string {
+	if defaultBranch := gitclient.GetDefaultBranch(ctx.repo.Path); defaultBranch != "" {
+		return defaultBranch
+	}
+	return branches.get(*ctx.repo)
+}
+
+// updateDefaultBranch switches a repository on a branch merged by the fetched changes, or on a branch gone at the
+// origin, to the default branch of the origin and fast-forwards it. The default commit is the one of the default
+// branch before fetching, branches without commits of their own or contained in the default branch before (eg. a
+// long-lived develop branch) are kept. Local branches without upstream are never switched, as they might not have
+// been pushed yet. Returns false if the repository is not affected and pulled as usual.
+func updateDefaultBranch(ctx *StateContext, defaultBranch string, defaultCommit string) bool {
+	repoDir := ctx.repo.Path
+	if ctx.ref == defaultBranch || ctx.ref == "HEAD" || !gitclient.IsRemoteBranch(repoDir, defaultBranch) {
+		return false
+	}
+
+	var reason string
+	upstream, gone := gitclient.GetUpstream(repoDir, ctx.ref)
+	switch {
+	case gone:
+		reason = "gone at the origin"
+	case upstream && gitclient.IsMergedSince(repoDir, "HEAD", defaultCommit, "origin/"+defaultBranch):
+		reason = "merged"
+	default:
+		return false
+	}
+	if gitclient.IsDirty(repoDir) {
+		ctx.state = dirty
+		ctx.message = fmt.Sprintf("Not switched to %s, %s is %s but has local changes (skipped)", defaultBranch, ctx.ref, reason)
+		return true
+	}
+
+	previous := ctx.ref
+	if err := gitclient.SwitchBranch(repoDir, defaultBranch); err != nil {
+		ctx.state = failed
+		ctx.message = fmt.Sprintf("Unable to switch from %s to %s: %s", previous, defaultBranch, err)
+		return true
+	}
+	ctx.ref = defaultBranch
+	ctx.state = dirty
+	ctx.message = fmt.Sprintf("Switched from %s (%s) to %s", previous, reason, defaultBranch)
+	ctx.pulled = true
+	ctx.result = gitclient.PullUpdated
+	ctx.behind = gitclient.GetBehindCount(repoDir, defaultBranch)
+	if ctx.behind == 0 {
+		return true
+	}
+	if !gitclient.MergeFFOnly(repoDir, "origin/"+defaultBranch) {
 		ctx.state = failed
+		ctx.message += ", can not be fast-forwarded"
+		return true
+	}
+	ctx.message += "\n" + gitclient.GetChanges(repoDir, ctx.behind)
+	return true
+}
+
+// defaultBranches looks up the default branches in the (cached) listing of the hosters, for repositories without
+// origin/HEAD. The listing of a hoster is only retrieved on first use.
+type defaultBranches struct {
+	mutex   sync.Mutex
+	hosters h.Hosters
+	listed  map[string]map[string]string // default branches by host and lowercase remote path
+}
+
+func (d *defaultBranches) get(repo model.RepoDir) string {
+	hoster := d.hosters.ByHost(repo.Host)
+	if hoster == nil || repo.RemotePath == "" {
+		return ""
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if _, exists := d.listed[repo.Host]; !exists {
+		d.listed[repo.Host] = map[string]string{}
+		for _, listed := range cache.Repositories(hoster, h.RequestOptions{Archived: true}, config.Values.Options.MaxAge, config.Values.Options.Refresh) {
+			d.listed[repo.Host][strings.ToLower(listed.PathWithNamespace)] = listed.DefaultBranch
+		}
+	}
+	return d.listed[repo.Host][strings.ToLower(repo.RemotePath)]
+}
+
+func updateUnshallow(ctx *StateContext) {
+	if !gitclient.IsShallow(ctx.repo.Path) {
+		ctx.state = clean
+		return
+	}
+	if !gitclient.Unshallow(ctx.repo.Path) {
+		ctx.state = failed
+		ctx.message = "Could not be unshallowed"
 		return
 	}
+	ctx.state = updated
+	ctx.message = "Unshallowed, complete history fetched"
+}
+
+func updateMirror(ctx *StateContext) {
+	updated, message := gitclient.RemoteUpdate(ctx.repo.Path)
+	if !updated {
+		ctx.state = failed
+		ctx.message = message
+		return
+	}
+	if message == "" {
+		ctx.state = clean
+		return
+	}
+	ctx.state = dirty
+	ctx.message = message
 }
 
 func printContext(ctx *StateContext) {
@@ -187,6 +410,8 @@ func printContext(ctx *StateContext) {
 		outState = aurora.Yellow("●").Bold().String()
 	case failed:
 		outState = aurora.Red("✖").Bold().String()
+	case updated:
+		outState = aurora.Green("↓").Bold().String()
 	default:
 		outState = "?"
 	}
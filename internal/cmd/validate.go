@@ -3,7 +3,6 @@ package cmd
 import (
 	"repo/internal/config"
 	h "repo/internal/hoster"
-	"repo/internal/hoster/gitlab"
 	"repo/internal/model"
 	"repo/internal/say"
 	"time"
@@ -20,6 +19,8 @@ func init() {
 	validateCmd.Flags().BoolVarP(&validateQuiet, "quiet", "q", false, "Output only affected repositories")
 	validateCmd.Flags().BoolVarP(&validateOptionalContacts, "optionalContacts", "e", false, "Allow empty contacts (existing contacts still will be validated)")
 	validateCmd.Flags().BoolVarP(&validateOptionalManifest, "optionalManifest", "m", false, "Allow repositories not containing a manifest file")
+	addSelectionFlags(validateCmd, "")
+	addCacheFlags(validateCmd)
 }
 
 var validateCmd = &cobra.Command{
@@ -29,24 +30,28 @@ var validateCmd = &cobra.Command{
 	Args:  validateConditions(cobra.ExactArgs(1), validateArgGitDir(0, true, true)),
 	Run: func(cmd *cobra.Command, args []string) {
 		config.Init(cmd.Flags())
-		hoster, err := gitlab.MakeHoster()
+		hosters, err := makeHosters()
 		handleFatalError(err)
 
 		dirReposRoot := getAbsoluteRepoRoot(args[0])
-		gitDirs := collectGitDirsHandled(dirReposRoot, hoster)
-		validateProcess(hoster, gitDirs, dirReposRoot)
+		gitDirs := selectGitDirs(cmd, dirReposRoot, collectGitDirsHandled(dirReposRoot, hosters), hosters)
+		validateProcess(hosters, gitDirs, dirReposRoot)
 	},
 }
 
-func validateProcess(hoster h.Hoster, gitDirs []model.RepoDir, dirReposRoot string) {
+func validateProcess(hosters h.Hosters, gitDirs []model.RepoDir, dirReposRoot string) {
 	defer say.Timer(time.Now())
 	counter := int32(0)
 
 	for _, gd := range gitDirs {
 		dirRepoRelative := getRelativRepoDir(gd.Path, dirReposRoot)
+		webUrl := getWebUrl(gd.Host, gd.RemotePath)
 
-		remotePath := model.DetermineRemotePath(gd.Path, hoster.Host())
-		webUrl := "https://" + hoster.Host() + "/" + remotePath
+		hoster := hosters.ByHost(gd.Host)
+		if hoster == nil {
+			say.ProgressWarn(&counter, len(gitDirs), nil, dirRepoRelative, webUrl, "- Unable to determine hoster for git remote (skipping)")
+			continue
+		}
 
 		say.Verbose("Validating %s", dirRepoRelative)
 		errValidate := hoster.Validate(gd.RepoMeta, config.Values.Options.OptionalManifest, config.Values.Options.OptionalContacts)
@@ -17,16 +17,28 @@ import (
 	"github.com/knadh/koanf/v2"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	yamlv2 "gopkg.in/yaml.v2"
 )
 
 var ConfigFile string
+var WorkspaceDir string // root-dir of the command, the workspace file is loaded from there
 var Values config
 
+const WorkspaceFilename string = ".repow.yaml"
+
 const (
 	StyleFlat      string = "flat"
 	StyleRecursive string = "recursive"
 )
 
+const (
+	TransportSsh   string = "ssh"
+	TransportHttps string = "https"
+)
+
+// Transports are the supported transports for git
+var Transports = []string{TransportSsh, TransportHttps}
+
 func Init(flags *pflag.FlagSet) {
 	initFailsafecheck()
 
@@ -34,6 +46,7 @@ func Init(flags *pflag.FlagSet) {
 
 	initLoadDefaults(k)
 	initLoadConfigfile(k)
+	initLoadWorkspace(k)
 	initLoadEnvs(k)
 	initLoadFlags(k, flags)
 
@@ -57,7 +70,10 @@ func initFailsafecheck() {
 func initLoadDefaults(k *koanf.Koanf) {
 	k.Load(structs.Provider(config{
 		Options: options{
+			Hoster:           "gitlab",
 			Style:            "flat",
+			Transport:        TransportSsh,
+			Strategy:         "ff-only",
 			Parallelism:      32,
 			OptionalManifest: false,
 			OptionalContacts: false,
@@ -65,12 +81,18 @@ func initLoadDefaults(k *koanf.Koanf) {
 		Server: server{
 			Port: 8080,
 		},
-		Gitlab: gitlab{
+		Gitlab: Hoster{
 			DownloadRetryCount: 6, // lower values didn't solve the issue
 			Host:               "gitlab.com",
 			SSHUser:            "git",
 			SSHPort:            22,
 		},
+		Github: Hoster{
+			DownloadRetryCount: 6,
+			Host:               "github.com",
+			SSHUser:            "git",
+			SSHPort:            22,
+		},
 	}, "koanf"), nil)
 	print(k, "loaded defaults")
 }
@@ -87,6 +109,95 @@ func initLoadConfigfile(k *koanf.Koanf) {
 	}
 }
 
+// workspaceKeys are the keys written by WriteWorkspace, the only ones loaded from a workspace file. The file might be
+// shared with a team, it must not change the hoster instances, their tokens or other settings.
+var workspaceKeys = []string{"options.hoster", "options.style", "options.hostprefix", "options.template"}
+
+func isWorkspaceKey(key string) bool {
+	return slices.Contains(workspaceKeys, key) || strings.HasPrefix(key, "filter.")
+}
+
+func initLoadWorkspace(k *koanf.Koanf) {
+	if WorkspaceDir == "" {
+		return
+	}
+	workspaceFile := filepath.Join(WorkspaceDir, WorkspaceFilename)
+	if _, err := os.Stat(workspaceFile); err != nil {
+		say.Verbose("Workspace-File %s does not exist\n", workspaceFile)
+		return
+	}
+	workspace := koanf.New(".")
+	if err := workspace.Load(file.Provider(workspaceFile), yaml.Parser()); err != nil {
+		say.Error("error loading workspace file: %v", err)
+		return
+	}
+	for _, key := range workspace.Keys() {
+		if !isWorkspaceKey(key) {
+			say.Error("Ignoring %s in workspace file %s, only options.hoster/style/hostprefix/template and filter are supported", key, workspaceFile)
+			continue
+		}
+		k.Set(key, workspace.Get(key))
+	}
+	print(k, "loaded workspace-file")
+}
+
+// WriteWorkspace stores the hoster, layout and filter in the workspace file of the directory
+func WriteWorkspace(dir string) error {
+	workspace := struct {
+		Options struct {
+			Hoster     string `yaml:"hoster"`
+			Style      string `yaml:"style"`
+			HostPrefix bool   `yaml:"hostprefix,omitempty"`
+			Template   string `yaml:"template,omitempty"`
+		} `yaml:"options"`
+		Filter Filter `yaml:"filter"`
+	}{Filter: Values.Filter}
+	workspace.Options.Hoster = Values.Options.Hoster
+	workspace.Options.Style = Values.Options.Style
+	workspace.Options.HostPrefix = Values.Options.HostPrefix
+	workspace.Options.Template = Values.Options.Template
+
+	content, err := yamlv2.Marshal(workspace)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, WorkspaceFilename), content, 0644)
+}
+
+// WriteWorkspaceLayout updates the style and path template in the workspace file of the directory, the other keys are
+// kept. Returns false, if the directory has no workspace file.
+func WriteWorkspaceLayout(dir string, style string, template string) (bool, error) {
+	workspaceFile := filepath.Join(dir, WorkspaceFilename)
+	content, err := os.ReadFile(workspaceFile)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	workspace := map[string]interface{}{}
+	if err := yamlv2.Unmarshal(content, &workspace); err != nil {
+		return false, err
+	}
+	options, _ := workspace["options"].(map[interface{}]interface{})
+	if options == nil {
+		options = map[interface{}]interface{}{}
+	}
+	options["style"] = style
+	if template == "" {
+		delete(options, "template")
+	} else {
+		options["template"] = template
+	}
+	workspace["options"] = options
+
+	content, err = yamlv2.Marshal(workspace)
+	if err != nil {
+		return false, err
+	}
+	return true, os.WriteFile(workspaceFile, content, 0644)
+}
+
 func initLoadEnvs(k *koanf.Koanf) {
 	k.Load(env.Provider("REPOW_", ".", func(s string) string {
 		return strings.Replace(strings.ToLower(strings.TrimPrefix(s, "REPOW_")), "_", ".", -1)
@@ -96,17 +207,47 @@ func initLoadEnvs(k *koanf.Koanf) {
 
 // Load flags (and do some ugly mapping)
 func initLoadFlags(k *koanf.Koanf, flags *pflag.FlagSet) {
-	p := posflag.ProviderWithValue(flags, ".", k, func(key string, value string) (string, any) {
+	p := posflag.ProviderWithFlag(flags, ".", k, func(f *pflag.Flag) (string, any) {
 		mappings := map[string]string{
+			"active-since":     "filter.activesince",
+			"autostash":        "options.autostash",
+			"annotation":       "filter.annotations",
+			"contact":          "filter.contacts",
+			"default-branch":   "options.defaultbranch",
+			"depth":            "options.depth",
+			"dry-run":          "options.dryrun",
+			"exclude":          "filter.exclude",
+			"filter":           "options.filter",
+			"group":            "filter.groups",
+			"hoster":           "options.hoster",
+			"host-prefix":      "options.hostprefix",
+			"include":          "filter.include",
+			"mirror":           "options.mirror",
+			"max-age":          "options.maxage",
+			"min-access-level": "filter.minaccesslevel",
+			"no-forks":         "filter.noforks",
 			"optionalContacts": "options.optionalcontacts",
 			"optionalManifest": "options.optionalmanifest",
+			"org":              "filter.org",
+			"owned":            "filter.owned",
 			"parallelism":      "options.parallelism",
+			"refresh":          "options.refresh",
+			"single-branch":    "options.singlebranch",
+			"starred":          "filter.starred",
+			"strategy":         "options.strategy",
 			"style":            "options.style",
+			"template":         "options.template",
+			"topic":            "filter.topics",
+			"transport":        "options.transport",
+			"type":             "filter.type",
+			"visibility":       "filter.visibility",
+			"where":            "filter.where",
 		}
-		if len(mappings[key]) > 0 {
-			return mappings[key], value
+		value := posflag.FlagVal(flags, f)
+		if len(mappings[f.Name]) > 0 {
+			return mappings[f.Name], value
 		}
-		return key, value
+		return f.Name, value
 	})
 	// Load flags with provider
 	if err := k.Load(p, nil); err != nil {
@@ -115,6 +256,69 @@ func initLoadFlags(k *koanf.Koanf, flags *pflag.FlagSet) {
 	print(k, "loaded flags")
 }
 
+// HosterEntries returns all configured hoster instances. The entries from the hosters list come first,
+// followed by the gitlab and github sections, which are named after their type.
+func HosterEntries() []Hoster {
+	var result []Hoster
+	names := map[string]bool{}
+	for _, entry := range Values.Hosters {
+		if entry.Name == "" {
+			entry.Name = entry.Type
+		}
+		result = append(result, withHosterDefaults(entry))
+		names[entry.Name] = true
+	}
+	gitlab, github := Values.Gitlab, Values.Github
+	gitlab.Name, gitlab.Type = "gitlab", "gitlab"
+	github.Name, github.Type = "github", "github"
+	for _, entry := range []Hoster{gitlab, github} {
+		if !names[entry.Name] {
+			result = append(result, withHosterDefaults(entry))
+		}
+	}
+	return result
+}
+
+// ValidateHosters checks that the names of the hoster instances in the hosters list are unique,
+// otherwise the instances can't be selected and the webhooks would share the same endpoint
+func ValidateHosters() error {
+	names := map[string]bool{}
+	for _, entry := range Values.Hosters {
+		name := entry.Name
+		if name == "" {
+			name = entry.Type
+		}
+		if names[name] {
+			return fmt.Errorf("duplicate hoster name in hosters: %q (set a unique name for each instance)", name)
+		}
+		names[name] = true
+	}
+	return nil
+}
+
+// HosterEntry returns the configured hoster instance with the given name
+func HosterEntry(name string) (Hoster, bool) {
+	for _, entry := range HosterEntries() {
+		if entry.Name == name {
+			return entry, true
+		}
+	}
+	return Hoster{}, false
+}
+
+func withHosterDefaults(entry Hoster) Hoster {
+	if entry.DownloadRetryCount == 0 {
+		entry.DownloadRetryCount = 6 // lower values didn't solve the issue
+	}
+	if entry.SSHUser == "" {
+		entry.SSHUser = "git"
+	}
+	if entry.SSHPort == 0 {
+		entry.SSHPort = 22
+	}
+	return entry
+}
+
 func DefaultConfigFile() string {
 	// points in most cases to "${HOME}/.confg/repow/repow.yaml"
 	configDir, err := os.UserConfigDir()
@@ -142,6 +346,26 @@ func print(k *koanf.Koanf, stage string) {
 	var kk = k.Copy()
 	kk.Set("gitlab.apitoken", sensitive(k.String("gitlab.apitoken")))
 	kk.Set("gitlab.secrettoken", sensitive(k.String("gitlab.secrettoken")))
+	kk.Set("github.apitoken", sensitive(k.String("github.apitoken")))
+	kk.Set("github.secrettoken", sensitive(k.String("github.secrettoken")))
+	if entries, ok := k.Get("hosters").([]interface{}); ok {
+		var masked []interface{}
+		for _, entry := range entries {
+			if values, ok := entry.(map[string]interface{}); ok {
+				maskedValues := map[string]interface{}{}
+				for key, value := range values {
+					maskedValues[key] = value
+				}
+				apiToken, _ := values["apitoken"].(string)
+				secretToken, _ := values["secrettoken"].(string)
+				maskedValues["apitoken"] = sensitive(apiToken)
+				maskedValues["secrettoken"] = sensitive(secretToken)
+				entry = maskedValues
+			}
+			masked = append(masked, entry)
+		}
+		kk.Set("hosters", masked)
+	}
 	say.Verbose("%s", kk.Sprint())
 }
 
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+// flags like the ones of the clone command, parsed with the arguments
+func cloneFlags(t *testing.T, args ...string) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("clone", pflag.ContinueOnError)
+	flags.StringP("style", "s", StyleFlat, "")
+	flags.StringP("hoster", "", "", "")
+	flags.StringSliceP("topic", "t", nil, "")
+	flags.StringSliceP("include", "i", nil, "")
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return flags
+}
+
+func setupWorkspace(t *testing.T, content string) string {
+	dir := t.TempDir()
+	ConfigFile = filepath.Join(t.TempDir(), "repow.yaml")
+	WorkspaceDir = dir
+	t.Cleanup(func() { ConfigFile, WorkspaceDir, Values = "", "", config{} })
+	if content != "" {
+		if err := os.WriteFile(filepath.Join(dir, WorkspaceFilename), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestValidateHosters(t *testing.T) {
+	t.Cleanup(func() { Values = config{} })
+	Values.Hosters = []Hoster{{Name: "work", Type: "gitlab"}, {Type: "gitlab"}, {Type: "github"}}
+	if err := ValidateHosters(); err != nil {
+		t.Errorf("unexpected error for unique names: %s", err)
+	}
+	Values.Hosters = append(Values.Hosters, Hoster{Name: "work", Type: "gitea"})
+	if err := ValidateHosters(); err == nil {
+		t.Error("expected error for duplicate name")
+	}
+}
+
+func TestWorkspaceRoundTrip(t *testing.T) {
+	dir := setupWorkspace(t, "")
+	Init(cloneFlags(t, "--style", StyleRecursive, "-t", "library", "-i", "^acme/"))
+	if err := WriteWorkspace(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	Values = config{}
+	Init(cloneFlags(t))
+	if Values.Options.Style != StyleRecursive || !slices.Equal(Values.Filter.Topics, []string{"library"}) || !slices.Equal(Values.Filter.Include, []string{"^acme/"}) {
+		t.Errorf("unexpected values loaded from the workspace file %+v %+v", Values.Options, Values.Filter)
+	}
+}
+
+func TestWorkspaceFlagPrecedence(t *testing.T) {
+	setupWorkspace(t, "options:\n  style: recursive\nfilter:\n  topics: [library]\n")
+	Init(cloneFlags(t, "--style", StyleFlat, "-t", "service"))
+	if Values.Options.Style != StyleFlat || !slices.Equal(Values.Filter.Topics, []string{"service"}) {
+		t.Errorf("expected flags to take precedence, got %+v %+v", Values.Options, Values.Filter)
+	}
+}
+
+func TestWriteWorkspaceLayout(t *testing.T) {
+	dir := setupWorkspace(t, "options:\n  hoster: work\n  style: flat\n  template: '{{.Path}}'\nfilter:\n  topics: [library]\n")
+	updated, err := WriteWorkspaceLayout(dir, StyleRecursive, "")
+	if err != nil || !updated {
+		t.Fatalf("expected workspace file to be updated, got %t %v", updated, err)
+	}
+
+	Init(cloneFlags(t))
+	if Values.Options.Style != StyleRecursive || Values.Options.Template != "" {
+		t.Errorf("unexpected layout %q %q", Values.Options.Style, Values.Options.Template)
+	}
+	if Values.Options.Hoster != "work" || !slices.Equal(Values.Filter.Topics, []string{"library"}) {
+		t.Errorf("expected other keys to be kept, got %q %v", Values.Options.Hoster, Values.Filter.Topics)
+	}
+	if updated, err := WriteWorkspaceLayout(t.TempDir(), StyleRecursive, ""); updated || err != nil {
+		t.Errorf("expected no workspace file to be written, got %t %v", updated, err)
+	}
+}
+
+func TestWorkspaceIgnoresOtherKeys(t *testing.T) {
+	setupWorkspace(t, "options:\n  style: recursive\n  transport: https\ngitlab:\n  host: gitlab.attacker.example\nhosters:\n  - type: gitea\n    host: gitea.attacker.example\n")
+	Init(cloneFlags(t))
+	if Values.Options.Style != StyleRecursive {
+		t.Errorf("expected style to be loaded from the workspace file, got %q", Values.Options.Style)
+	}
+	if Values.Gitlab.Host != "gitlab.com" || len(Values.Hosters) != 0 || Values.Options.Transport != TransportSsh {
+		t.Errorf("expected hosters and transport not to be loaded from the workspace file, got %+v %+v", Values.Gitlab, Values.Hosters)
+	}
+}
@@ -1,31 +1,79 @@
 package config
 
+import "time"
+
 type config struct {
-	Options options `koanf:"options"`
-	Server  server  `koanf:"server"`
-	Gitlab  gitlab  `koanf:"gitlab"`
-	Slack   slack   `koanf:"slack"`
+	Options options  `koanf:"options"`
+	Filter  Filter   `koanf:"filter"`
+	Server  server   `koanf:"server"`
+	Gitlab  Hoster   `koanf:"gitlab"`
+	Github  Hoster   `koanf:"github"`
+	Hosters []Hoster `koanf:"hosters"`
+	Slack   slack    `koanf:"slack"`
 }
 
 type options struct {
-	Style            string `koanf:"style"`
-	Parallelism      int    `koanf:"parallelism"`
-	OptionalManifest bool   `koanf:"optionalmanifest"`
-	OptionalContacts bool   `koanf:"optionalcontacts"`
+	Hoster           string        `koanf:"hoster"`
+	Style            string        `koanf:"style"`
+	HostPrefix       bool          `koanf:"hostprefix"`
+	Template         string        `koanf:"template"`
+	Transport        string        `koanf:"transport"`
+	Parallelism      int           `koanf:"parallelism"`
+	OptionalManifest bool          `koanf:"optionalmanifest"`
+	OptionalContacts bool          `koanf:"optionalcontacts"`
+	Depth            int           `koanf:"depth"`
+	Filter           string        `koanf:"filter"`
+	SingleBranch     bool          `koanf:"singlebranch"`
+	Mirror           bool          `koanf:"mirror"`
+	MaxAge           time.Duration `koanf:"maxage"`
+	Refresh          bool          `koanf:"refresh"`
+	DryRun           bool          `koanf:"dryrun"`
+	DefaultBranch    bool          `koanf:"defaultbranch"`
+	Strategy         string        `koanf:"strategy"`
+	Autostash        bool          `koanf:"autostash"`
+}
+
+// Filter selects the repositories of a hoster
+type Filter struct {
+	Topics  []string `koanf:"topics" yaml:"topics,omitempty"`
+	Include []string `koanf:"include" yaml:"include,omitempty"`
+	Exclude []string `koanf:"exclude" yaml:"exclude,omitempty"`
+	Groups  []string `koanf:"groups" yaml:"groups,omitempty"`
+	Starred bool     `koanf:"starred" yaml:"starred,omitempty"`
+
+	Visibility     string `koanf:"visibility" yaml:"visibility,omitempty"`
+	NoForks        bool   `koanf:"noforks" yaml:"noforks,omitempty"`
+	Owned          bool   `koanf:"owned" yaml:"owned,omitempty"`
+	MinAccessLevel string `koanf:"minaccesslevel" yaml:"minaccesslevel,omitempty"`
+	ActiveSince    string `koanf:"activesince" yaml:"activesince,omitempty"` // eg. "90d"
+
+	// filters on the repo.yaml of the repositories
+	Type        string   `koanf:"type" yaml:"type,omitempty"`
+	Org         []string `koanf:"org" yaml:"org,omitempty"`                 // "key=value"
+	Annotations []string `koanf:"annotations" yaml:"annotations,omitempty"` // "key=value"
+	Contacts    []string `koanf:"contacts" yaml:"contacts,omitempty"`
+
+	Where string `koanf:"where" yaml:"where,omitempty"` // boolean expression, eg. "topic:library && !path:^legacy/"
 }
 
 type server struct {
 	Port int `koanf:"port"`
 }
 
-type gitlab struct {
+// Hoster contains the settings for a single hoster instance
+type Hoster struct {
+	Name               string `koanf:"name"`
+	Type               string `koanf:"type"`
 	Host               string `koanf:"host"`
 	ApiToken           string `koanf:"apitoken"`
 	DownloadRetryCount int    `koanf:"downloadretrycount"`
 	SecretToken        string `koanf:"secrettoken"`
 	SSHUser            string `koanf:"sshuser"`
 	SSHPort            int    `koanf:"sshport"`
+	Username           string `koanf:"username"` // username for the API-token with the https transport, if required by the hoster
+	File               string `koanf:"file"`
 }
+
 type slack struct {
 	Token     string `koanf:"token"`
 	ChannelId string `koanf:"channelid"`
@@ -2,6 +2,7 @@ package gitclient
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -16,16 +17,66 @@ import (
 func PrepareSsh(host string, sshUser string, sshPort int) {
 	// not sure how to improve this.
 	// maybe load ssh config if available and determine identity for host?
-	_, e, code := util.RunCommandDir(nil, "ssh", "-p", strconv.Itoa(sshPort), fmt.Sprintf("%s@%s", sshUser, host))
-	if code != 0 {
+	_, e, code := util.RunCommandDir(nil, "ssh", "-T", "-p", strconv.Itoa(sshPort), fmt.Sprintf("%s@%s", sshUser, host))
+	// ssh itself exits with 255 on connection or authentication errors, other codes are from the hoster after
+	// successful authentication (eg. github exits with 1, as it does not provide shell access)
+	if code == 255 {
 		say.Error("Failed loading ssh key: %s", e)
 		os.Exit(3)
 	}
 }
 
-func Clone(rootDir string, repoDir string, sshUrl string) error {
+// CloneOptions reduce the history and objects being cloned
+type CloneOptions struct {
+	Depth        int    // limits the history to the number of commits, 0 for the complete history
+	Filter       string // partial clone filter, eg. "blob:none" or "tree:0"
+	SingleBranch bool   // clones only the default branch
+	Mirror       bool   // creates a bare mirror of all references
+	ProjectId    int    // ID of the repository at the hoster, stored in the repository configuration to follow renames
+	// credential helper stored in the repository configuration, provides the API-token for https remotes
+	CredentialHelper string
+}
+
+// key of the repository configuration for the ID of the repository at the hoster
+const projectIdKey = "repow.projectid"
+
+// GetProjectId returns the ID of the repository at the hoster stored when cloning, 0 if it is unknown
+func GetProjectId(repoDir string) int {
+	o, _, _ := util.RunCommandDir(&repoDir, "git", "config", "--get", projectIdKey)
+	id, _ := strconv.Atoi(strings.TrimSpace(o))
+	return id
+}
+
+// CloneFilters are the supported partial clone filters
+var CloneFilters = []string{"blob:none", "tree:0"}
+
+func (o CloneOptions) args() []string {
+	var result []string
+	if o.Depth > 0 {
+		result = append(result, "--depth", strconv.Itoa(o.Depth))
+	}
+	if o.Filter != "" {
+		result = append(result, "--filter="+o.Filter)
+	}
+	if o.SingleBranch {
+		result = append(result, "--single-branch")
+	}
+	if o.Mirror {
+		result = append(result, "--mirror")
+	}
+	if o.CredentialHelper != "" {
+		result = append(result, "-c", "credential.helper="+o.CredentialHelper)
+	}
+	if o.ProjectId > 0 {
+		result = append(result, "-c", projectIdKey+"="+strconv.Itoa(o.ProjectId))
+	}
+	return result
+}
+
+func Clone(rootDir string, repoDir string, sshUrl string, options CloneOptions) error {
 	dirRepository := path.Join(rootDir, repoDir)
-	cmdGo := exec.Command("git", "clone", sshUrl, dirRepository)
+	args := append(append([]string{"clone"}, options.args()...), sshUrl, dirRepository)
+	cmdGo := exec.Command("git", args...)
 	if say.VerboseEnabled {
 		cmdGo.Stdout = os.Stdout
 		cmdGo.Stderr = os.Stderr
@@ -53,7 +104,11 @@ func GetLocalChanges(repoDir string) string {
 }
 
 func IsEmpty(repoDir string) bool {
-	fis, err := ioutil.ReadDir(path.Join(repoDir, ".git/objects"))
+	dirObjects := path.Join(repoDir, ".git/objects")
+	if IsBare(repoDir) {
+		dirObjects = path.Join(repoDir, "objects")
+	}
+	fis, err := ioutil.ReadDir(dirObjects)
 	if err != nil {
 		return true
 	}
@@ -91,17 +146,345 @@ func GetChanges(repoDir string, behind int) string {
 	return strings.TrimSpace(o)
 }
 
+// GetHeadCommit returns the full hash of the checked out commit, empty for repositories without commits
+func GetHeadCommit(repoDir string) string {
+	return GetCommit(repoDir, "HEAD")
+}
+
+// GetCommit returns the full hash of the commit the ref points to, empty if the ref does not exist
+func GetCommit(repoDir string, ref string) string {
+	o, _, code := util.RunCommandDir(&repoDir, "git", "rev-parse", "--verify", "-q", ref+"^{commit}")
+	if code != 0 {
+		return ""
+	}
+	return strings.TrimSpace(o)
+}
+
+// Checkout checks out the commit, either on the (re)created branch or detached if no branch is given.
+// Commits not reachable by the cloned references are fetched from the origin.
+func Checkout(repoDir string, branch string, commit string) error {
+	if _, _, code := util.RunCommandDir(&repoDir, "git", "cat-file", "-e", commit+"^{commit}"); code != 0 {
+		if _, e, code := util.RunCommandDir(&repoDir, "git", "fetch", "-q", "origin", commit); code != 0 {
+			return fmt.Errorf("commit %s is not available at the origin: %s", commit, strings.TrimSpace(e))
+		}
+	}
+	args := []string{"checkout", "-q", "--detach", commit}
+	if branch != "" {
+		args = []string{"checkout", "-q", "-B", branch, commit}
+	}
+	if _, e, code := util.RunCommandDir(&repoDir, "git", args...); code != 0 {
+		return errors.New(strings.TrimSpace(e))
+	}
+	if branch != "" && IsRemoteBranch(repoDir, branch) {
+		util.RunCommandDir(&repoDir, "git", "branch", "-q", "--set-upstream-to", "origin/"+branch, branch)
+	}
+	return nil
+}
+
+// IsRemoteBranch checks for the remote-tracking branch of the origin
+func IsRemoteBranch(repoDir string, branch string) bool {
+	_, _, code := util.RunCommandDir(&repoDir, "git", "rev-parse", "--verify", "-q", "refs/remotes/origin/"+branch)
+	return code == 0
+}
+
 func IsRemoteExisting(repoDir string, ref string) bool {
 	o, _, _ := util.RunCommandDir(&repoDir, "git", "ls-remote", ".", "refs/remotes/origin/"+ref)
 	return len(o) >= 0
 }
 
+// LsRemote lists the references of the remote repository, returns the error output on failure
+func LsRemote(url string) (bool, string) {
+	_, e, code := util.RunCommandDir(nil, "git", "ls-remote", "--heads", url)
+	return code == 0, strings.TrimSpace(e)
+}
+
+func GetRemoteUrl(repoDir string) string {
+	o, _, _ := util.RunCommandDir(&repoDir, "git", "remote", "get-url", "origin")
+	return strings.TrimSpace(o)
+}
+
+func SetRemoteUrl(repoDir string, url string) error {
+	_, e, code := util.RunCommandDir(&repoDir, "git", "remote", "set-url", "origin", url)
+	if code != 0 {
+		return errors.New(strings.TrimSpace(e))
+	}
+	return nil
+}
+
+// IsBare checks for the layout of a bare repository, without running git
+func IsBare(dir string) bool {
+	return util.ExistsFile(path.Join(dir, "HEAD")) && util.ExistsDir(path.Join(dir, "objects")) && util.ExistsDir(path.Join(dir, "refs"))
+}
+
+// RemoteUpdate updates all references of a (mirrored) repository and returns the updated references
+func RemoteUpdate(repoDir string) (bool, string) {
+	_, e, code := util.RunCommandDir(&repoDir, "git", "remote", "update", "--prune")
+	var updated []string
+	for _, line := range strings.Split(e, "\n") {
+		if strings.Contains(line, "->") {
+			updated = append(updated, strings.TrimSpace(line))
+		}
+	}
+	if code != 0 {
+		return false, strings.TrimSpace(e)
+	}
+	return true, strings.Join(updated, "\n")
+}
+
+func IsShallow(repoDir string) bool {
+	o, _, _ := util.RunCommandDir(&repoDir, "git", "rev-parse", "--is-shallow-repository")
+	return strings.TrimSpace(o) == "true"
+}
+
+// Unshallow fetches the complete history of a shallow repository
+func Unshallow(repoDir string) bool {
+	_, _, code := util.RunCommandDir(&repoDir, "git", "fetch", "-q", "--unshallow")
+	return code == 0
+}
+
 func Fetch(repoDir string) bool {
 	_, _, code := util.RunCommandDir(&repoDir, "git", "fetch", "-q")
 	return code == 0
 }
 
-func MergeFF(repoDir string) bool {
-	_, _, code := util.RunCommandDir(&repoDir, "git", "merge", "FETCH_HEAD", "--ff")
+// FetchPrune fetches and removes the remote-tracking branches, which no longer exist at the origin
+func FetchPrune(repoDir string) bool {
+	_, _, code := util.RunCommandDir(&repoDir, "git", "fetch", "-q", "--prune")
 	return code == 0
 }
+
+// GetDefaultBranch returns the default branch of the origin as known by origin/HEAD, empty if it is not set
+func GetDefaultBranch(repoDir string) string {
+	o, _, code := util.RunCommandDir(&repoDir, "git", "symbolic-ref", "-q", "--short", "refs/remotes/origin/HEAD")
+	if code != 0 {
+		return ""
+	}
+	return strings.TrimPrefix(strings.TrimSpace(o), "origin/")
+}
+
+// GetUpstream returns if the branch has an upstream configured, and if the upstream branch is gone at the origin
+func GetUpstream(repoDir string, branch string) (bool, bool) {
+	o, _, _ := util.RunCommandDir(&repoDir, "git", "for-each-ref", "--format=%(upstream)|%(upstream:track)", "refs/heads/"+branch)
+	upstream, track, _ := strings.Cut(strings.TrimSpace(o), "|")
+	return upstream != "", track == "[gone]"
+}
+
+// IsMerged checks if the commit is reachable from the ref, eg. a merged branch from the remote default branch
+func IsMerged(repoDir string, commit string, ref string) bool {
+	_, _, code := util.RunCommandDir(&repoDir, "git", "merge-base", "--is-ancestor", commit, ref)
+	return code == 0
+}
+
+// IsMergedSince checks if the commit has been merged into the ref since it pointed to the previous commit. Branches
+// without commits of their own, or already contained in the previous commit (eg. a long-lived develop branch), are
+// not merged since.
+func IsMergedSince(repoDir string, commit string, previous string, ref string) bool {
+	return previous != "" && !IsMerged(repoDir, commit, previous) && IsMerged(repoDir, commit, ref)
+}
+
+// SwitchBranch checks out the branch, a missing local branch is created tracking the one of the origin
+func SwitchBranch(repoDir string, branch string) error {
+	args := []string{"checkout", "-q", branch}
+	if _, _, code := util.RunCommandDir(&repoDir, "git", "rev-parse", "--verify", "-q", "refs/heads/"+branch); code != 0 {
+		args = []string{"checkout", "-q", "-b", branch, "--track", "origin/" + branch}
+	}
+	if _, e, code := util.RunCommandDir(&repoDir, "git", args...); code != 0 {
+		return errors.New(strings.TrimSpace(e))
+	}
+	return nil
+}
+
+// MergeFFOnly fast-forwards the current branch to the ref, fails if the branch has diverged
+func MergeFFOnly(repoDir string, ref string) bool {
+	_, _, code := util.RunCommandDir(&repoDir, "git", "merge", "-q", "--ff-only", ref)
+	return code == 0
+}
+
+// Strategies for integrating the changes of the upstream when pulling
+const (
+	PullFFOnly string = "ff-only"
+	PullRebase string = "rebase"
+	PullMerge  string = "merge"
+)
+
+// PullStrategies are the supported strategies for pulling
+var PullStrategies = []string{PullFFOnly, PullRebase, PullMerge}
+
+// PullResult is the outcome of pulling the changes of the upstream
+type PullResult int
+
+const (
+	PullUnchanged PullResult = iota // nothing to integrate, the branch contains the ref already
+	PullUpdated                     // fast-forwarded, there were no local commits
+	PullRebased                     // local commits have been rebased onto the upstream
+	PullMerged                      // local commits have been merged with the upstream
+	PullConflict                    // conflicting changes, the pull was aborted and the previous state restored
+	PullFailed                      // refused before changing anything, eg. local changes or local commits with ff-only
+)
+
+// Pull integrates the ref (eg. "origin/main") into the current branch with the strategy. With autostash local
+// changes are stashed before and applied afterwards. On conflicts, also when applying the stashed changes, the
+// pull is aborted and the branch, working tree and local changes are restored, no rebase or merge is left in progress.
+func Pull(repoDir string, ref string, strategy string, autostash bool) (PullResult, error) {
+	if countCommits(repoDir, "HEAD.."+ref) == 0 {
+		return PullUnchanged, nil
+	}
+	dirty := IsDirty(repoDir)
+	if dirty && !autostash && strategy != PullFFOnly {
+		// conflicts could mix with local changes, a fast-forward is refused by git if local changes are affected
+		return PullFailed, errors.New("local changes, use --autostash")
+	}
+	ahead := countCommits(repoDir, ref+"..HEAD")
+	if ahead > 0 && strategy == PullFFOnly {
+		return PullFailed, fmt.Errorf("local commits (%d) can not be fast-forwarded, use --strategy rebase or merge", ahead)
+	}
+	head := GetHeadCommit(repoDir)
+
+	stash := ""
+	if dirty && autostash {
+		var err error
+		if stash, err = pushStash(repoDir); err != nil {
+			return PullFailed, err
+		}
+	}
+
+	var args []string
+	switch strategy {
+	case PullRebase:
+		args = []string{"rebase", "-q", ref}
+	case PullMerge:
+		args = []string{"merge", "-q", "--no-edit", ref}
+	default:
+		args = []string{"merge", "-q", "--ff-only", ref}
+	}
+	_, e, code := util.RunCommandDir(&repoDir, "git", args...)
+	if code != 0 {
+		if !isInProgress(repoDir) {
+			// refused by git without changing anything, local changes which are not stashed have to be kept
+			if stash != "" {
+				if err := restore(repoDir, head, stash); err != nil {
+					return PullFailed, err
+				}
+			}
+			return PullFailed, errors.New(strings.TrimSpace(e))
+		}
+		conflicts := hasConflicts(repoDir)
+		if err := restore(repoDir, head, stash); err != nil {
+			return PullConflict, err
+		}
+		if !conflicts {
+			return PullFailed, fmt.Errorf("%s (aborted and restored)", strings.TrimSpace(e))
+		}
+		return PullConflict, nil
+	}
+	if stash != "" {
+		if err := popStash(repoDir, stash); err != nil {
+			// the stash is kept by git on conflicts, the update is undone to apply it to the previous state again
+			if err := restore(repoDir, head, stash); err != nil {
+				return PullConflict, err
+			}
+			return PullConflict, nil
+		}
+	}
+
+	switch {
+	case ahead == 0:
+		return PullUpdated, nil
+	case strategy == PullRebase:
+		return PullRebased, nil
+	}
+	return PullMerged, nil
+}
+
+// number of commits in the revision range, eg. "HEAD..origin/main"
+func countCommits(repoDir string, revisionRange string) int {
+	o, _, _ := util.RunCommandDir(&repoDir, "git", "rev-list", "--count", revisionRange)
+	count, _ := strconv.Atoi(strings.TrimSpace(o))
+	return count
+}
+
+// checks for unmerged files of a rebase or merge in progress
+func hasConflicts(repoDir string) bool {
+	o, _, _ := util.RunCommandDir(&repoDir, "git", "diff", "--name-only", "--diff-filter=U")
+	return strings.TrimSpace(o) != ""
+}
+
+// checks for a rebase or merge in progress
+func isInProgress(repoDir string) bool {
+	for _, name := range []string{"rebase-merge", "rebase-apply", "MERGE_HEAD"} {
+		o, _, _ := util.RunCommandDir(&repoDir, "git", "rev-parse", "--git-path", name)
+		p := strings.TrimSpace(o)
+		if !path.IsAbs(p) {
+			p = path.Join(repoDir, p)
+		}
+		if _, err := os.Stat(p); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// the latest stash, empty if there is none
+func topStash(repoDir string) string {
+	o, _, _ := util.RunCommandDir(&repoDir, "git", "rev-parse", "-q", "--verify", "refs/stash")
+	return strings.TrimSpace(o)
+}
+
+// pushStash stashes the local changes including untracked files and returns the created stash. Fails if git did not
+// create a stash, stashes of the user must never be applied instead.
+func pushStash(repoDir string) (string, error) {
+	before := topStash(repoDir)
+	if _, e, code := util.RunCommandDir(&repoDir, "git", "stash", "push", "-q", "--include-untracked", "-m", "repow autostash"); code != 0 {
+		return "", fmt.Errorf("unable to stash local changes: %s", strings.TrimSpace(e))
+	}
+	after := topStash(repoDir)
+	if after == "" || after == before {
+		return "", errors.New("unable to stash local changes, no stash has been created")
+	}
+	return after, nil
+}
+
+// popStash applies and drops the stash created by pushStash, if it is still the latest one
+func popStash(repoDir string, stash string) error {
+	if topStash(repoDir) != stash {
+		return fmt.Errorf("the stashed local changes are no longer the latest stash, they are kept as %s", stash)
+	}
+	if _, e, code := util.RunCommandDir(&repoDir, "git", "stash", "pop", "-q", "stash@{0}"); code != 0 {
+		return errors.New(strings.TrimSpace(e))
+	}
+	return nil
+}
+
+// removeStashedUntracked removes the untracked files of the stash from the working tree, which are left by a failed
+// apply of the stash and are not removed by a reset. Otherwise applying the stash again fails, as they already exist.
+func removeStashedUntracked(repoDir string, stash string) {
+	o, _, code := util.RunCommandDir(&repoDir, "git", "ls-tree", "-r", "-z", "--name-only", stash+"^3")
+	if code != 0 {
+		return // the stash contains no untracked files
+	}
+	for _, name := range strings.Split(o, "\x00") {
+		if name != "" {
+			os.Remove(path.Join(repoDir, name))
+		}
+	}
+}
+
+// restore aborts a rebase or merge in progress, resets the branch to the previous commit and applies the stash.
+// Local changes have to be stashed before (stash is not empty then), or the working tree has to be clean.
+func restore(repoDir string, head string, stash string) error {
+	util.RunCommandDir(&repoDir, "git", "rebase", "--abort")
+	util.RunCommandDir(&repoDir, "git", "merge", "--abort")
+	if isInProgress(repoDir) {
+		return errors.New("unable to abort the rebase or merge, resolve it manually")
+	}
+	if _, e, code := util.RunCommandDir(&repoDir, "git", "reset", "-q", "--hard", head); code != 0 {
+		return fmt.Errorf("unable to restore %s: %s", head, strings.TrimSpace(e))
+	}
+	if stash != "" {
+		removeStashedUntracked(repoDir, stash)
+		if err := popStash(repoDir, stash); err != nil {
+			return fmt.Errorf("unable to apply the stashed local changes, they are kept in the stash: %s", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package gitclient
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"repo/internal/util"
+)
+
+// runs git in the directory and fails the test on errors
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	o, e, code := util.RunCommandDir(&dir, "git", args...)
+	if code != 0 {
+		t.Fatalf("git %s failed: %s", strings.Join(args, " "), e)
+	}
+	return strings.TrimSpace(o)
+}
+
+func writeFile(t *testing.T, dir string, name string, content string) {
+	t.Helper()
+	if err := os.WriteFile(path.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func commit(t *testing.T, dir string, name string, content string) {
+	t.Helper()
+	writeFile(t, dir, name, content)
+	git(t, dir, "add", name)
+	git(t, dir, "commit", "-q", "-m", "change "+name)
+}
+
+// setup creates an origin with a commit on main and returns a clone of it and a second clone pushing to the origin
+func setup(t *testing.T) (string, string) {
+	t.Helper()
+	root := t.TempDir()
+	t.Setenv("GIT_CONFIG_GLOBAL", path.Join(root, "gitconfig"))
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	for key, value := range map[string]string{"user.name": "repow", "user.email": "repow@localhost", "init.defaultBranch": "main"} {
+		git(t, root, "config", "--global", key, value)
+	}
+	origin := path.Join(root, "origin.git")
+	git(t, root, "init", "-q", "--bare", origin)
+	upstream := path.Join(root, "upstream")
+	git(t, root, "clone", "-q", origin, upstream)
+	commit(t, upstream, "file.txt", "base\n")
+	git(t, upstream, "push", "-q", "origin", "main")
+	local := path.Join(root, "local")
+	git(t, root, "clone", "-q", origin, local)
+	return local, upstream
+}
+
+// pushes a change of the file by the upstream and fetches it in the local repository
+func remoteChange(t *testing.T, local string, upstream string, name string, content string) {
+	t.Helper()
+	commit(t, upstream, name, content)
+	git(t, upstream, "push", "-q", "origin", "main")
+	git(t, local, "fetch", "-q")
+}
+
+// asserts the repository is at the commit, nothing is in progress and the number of stashes is left
+func assertRestored(t *testing.T, local string, head string, stashes int) {
+	t.Helper()
+	if got := GetHeadCommit(local); got != head {
+		t.Errorf("expected HEAD to be restored to %s, got %s", head, got)
+	}
+	if isInProgress(local) {
+		t.Errorf("expected no rebase or merge in progress")
+	}
+	if got := git(t, local, "stash", "list"); len(strings.FieldsFunc(got, func(r rune) bool { return r == '\n' })) != stashes {
+		t.Errorf("expected %d stashes, got %q", stashes, got)
+	}
+}
+
+func readFile(t *testing.T, dir string, name string) string {
+	t.Helper()
+	content, err := os.ReadFile(path.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestPullFFOnlyWithLocalCommits(t *testing.T) {
+	local, upstream := setup(t)
+	remoteChange(t, local, upstream, "remote.txt", "remote\n")
+	commit(t, local, "local.txt", "local\n")
+	head := GetHeadCommit(local)
+
+	result, err := Pull(local, "origin/main", PullFFOnly, false)
+	if result != PullFailed || err == nil || !strings.Contains(err.Error(), "local commits (1)") {
+		t.Errorf("expected ff-only to fail for local commits, got %d %v", result, err)
+	}
+	assertRestored(t, local, head, 0)
+
+	result, err = Pull(local, "origin/main", PullRebase, false)
+	if result != PullRebased || err != nil {
+		t.Errorf("expected rebase to succeed, got %d %v", result, err)
+	}
+}
+
+func TestPullRebaseConflict(t *testing.T) {
+	local, upstream := setup(t)
+	remoteChange(t, local, upstream, "file.txt", "remote\n")
+	commit(t, local, "file.txt", "local\n")
+	writeFile(t, local, "other.txt", "uncommitted\n")
+	head := GetHeadCommit(local)
+
+	result, err := Pull(local, "origin/main", PullRebase, true)
+	if result != PullConflict || err != nil {
+		t.Errorf("expected conflict, got %d %v", result, err)
+	}
+	assertRestored(t, local, head, 0)
+	if readFile(t, local, "file.txt") != "local\n" || readFile(t, local, "other.txt") != "uncommitted\n" {
+		t.Errorf("expected local commit and changes to be restored")
+	}
+}
+
+func TestPullMergeConflict(t *testing.T) {
+	local, upstream := setup(t)
+	remoteChange(t, local, upstream, "file.txt", "remote\n")
+	commit(t, local, "file.txt", "local\n")
+	head := GetHeadCommit(local)
+
+	result, err := Pull(local, "origin/main", PullMerge, false)
+	if result != PullConflict || err != nil {
+		t.Errorf("expected conflict, got %d %v", result, err)
+	}
+	assertRestored(t, local, head, 0)
+	if IsDirty(local) {
+		t.Errorf("expected clean working tree after restoring")
+	}
+}
+
+func TestPullAutostashConflict(t *testing.T) {
+	local, upstream := setup(t)
+	remoteChange(t, local, upstream, "file.txt", "remote\n")
+	writeFile(t, local, "file.txt", "uncommitted\n")
+	head := GetHeadCommit(local)
+
+	result, err := Pull(local, "origin/main", PullFFOnly, true)
+	if result != PullConflict || err != nil {
+		t.Errorf("expected conflict applying the stash, got %d %v", result, err)
+	}
+	assertRestored(t, local, head, 0)
+	if readFile(t, local, "file.txt") != "uncommitted\n" {
+		t.Errorf("expected local changes to be restored")
+	}
+}
+
+func TestPullAutostashConflictWithUntracked(t *testing.T) {
+	local, upstream := setup(t)
+	remoteChange(t, local, upstream, "file.txt", "remote\n")
+	writeFile(t, local, "file.txt", "uncommitted\n")
+	writeFile(t, local, "untracked.txt", "untracked\n")
+	head := GetHeadCommit(local)
+
+	result, err := Pull(local, "origin/main", PullFFOnly, true)
+	if result != PullConflict || err != nil {
+		t.Errorf("expected conflict applying the stash, got %d %v", result, err)
+	}
+	assertRestored(t, local, head, 0)
+	if readFile(t, local, "file.txt") != "uncommitted\n" || readFile(t, local, "untracked.txt") != "untracked\n" {
+		t.Errorf("expected local changes and untracked file to be restored")
+	}
+}
+
+func TestPullAutostashUntrackedOnly(t *testing.T) {
+	local, upstream := setup(t)
+	// an older stash of the user, which must not be applied
+	writeFile(t, local, "file.txt", "user stash\n")
+	git(t, local, "stash", "push", "-q", "-m", "user stash")
+	remoteChange(t, local, upstream, "remote.txt", "remote\n")
+	commit(t, local, "local.txt", "local\n")
+	writeFile(t, local, "untracked.txt", "untracked\n")
+
+	result, err := Pull(local, "origin/main", PullRebase, true)
+	if result != PullRebased || err != nil {
+		t.Errorf("expected rebase to succeed, got %d %v", result, err)
+	}
+	if readFile(t, local, "file.txt") != "base\n" || readFile(t, local, "untracked.txt") != "untracked\n" {
+		t.Errorf("expected untracked file to be kept and the user stash not to be applied")
+	}
+	assertRestored(t, local, GetHeadCommit(local), 1)
+	if got := git(t, local, "stash", "list"); !strings.Contains(got, "user stash") {
+		t.Errorf("expected the user stash to be left, got %q", got)
+	}
+}
+
+func TestIsMergedSince(t *testing.T) {
+	local, upstream := setup(t)
+	git(t, upstream, "checkout", "-q", "-b", "develop")
+	git(t, upstream, "push", "-q", "origin", "develop")
+	git(t, upstream, "checkout", "-q", "-b", "feature")
+	commit(t, upstream, "feature.txt", "feature\n")
+	git(t, upstream, "push", "-q", "origin", "feature")
+	git(t, local, "fetch", "-q")
+	previous := GetCommit(local, "origin/main")
+	feature := GetCommit(local, "origin/feature")
+	develop := GetCommit(local, "origin/develop")
+
+	if IsMergedSince(local, feature, previous, "origin/main") {
+		t.Errorf("expected feature not to be merged before the merge")
+	}
+	git(t, upstream, "checkout", "-q", "main")
+	git(t, upstream, "merge", "-q", "--no-ff", "--no-edit", "feature")
+	git(t, upstream, "push", "-q", "origin", "main")
+	git(t, local, "fetch", "-q")
+
+	if !IsMergedSince(local, feature, previous, "origin/main") {
+		t.Errorf("expected feature to be merged by the fetched changes")
+	}
+	if IsMergedSince(local, develop, previous, "origin/main") {
+		t.Errorf("expected develop without commits of its own not to be merged")
+	}
+	if IsMergedSince(local, feature, GetCommit(local, "origin/main"), "origin/main") {
+		t.Errorf("expected feature merged before not to be merged since")
+	}
+	if IsMergedSince(local, feature, "", "origin/main") {
+		t.Errorf("expected no merge without previous commit")
+	}
+}
+
+func TestCloneStoresProjectId(t *testing.T) {
+	local, _ := setup(t)
+	if id := GetProjectId(local); id != 0 {
+		t.Errorf("expected no project id, got %d", id)
+	}
+	root := path.Dir(local)
+	if err := Clone(root, "with-id", path.Join(root, "origin.git"), CloneOptions{ProjectId: 42}); err != nil {
+		t.Fatal(err)
+	}
+	if id := GetProjectId(path.Join(root, "with-id")); id != 42 {
+		t.Errorf("expected project id 42, got %d", id)
+	}
+}
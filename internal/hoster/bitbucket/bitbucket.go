@@ -0,0 +1,346 @@
+package bitbucket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"repo/internal/config"
+	"repo/internal/hoster"
+	"repo/internal/model"
+	"repo/internal/say"
+)
+
+const HosterType string = "bitbucket"
+
+func init() {
+	hoster.Register(HosterType, func(cfg config.Hoster) (hoster.Hoster, error) {
+		return MakeHoster(cfg)
+	})
+}
+
+// MakeHoster creates a hoster for Bitbucket Server and Data Center, using the REST API 1.0
+func MakeHoster(cfg config.Hoster) (*Bitbucket, error) {
+	result := &Bitbucket{cfg: cfg, client: &http.Client{Timeout: 60 * time.Second}}
+	if cfg.Host == "" {
+		return result, fmt.Errorf("the Bitbucket host has to be set (hoster %s)", cfg.Name)
+	}
+	if cfg.ApiToken == "" {
+		return result, fmt.Errorf("the Bitbucket API-token has to be set (hoster %s)", cfg.Name)
+	}
+	return result, nil
+}
+
+type Bitbucket struct {
+	cfg    config.Hoster
+	client *http.Client
+}
+
+type page struct {
+	IsLastPage    bool            `json:"isLastPage"`
+	NextPageStart int             `json:"nextPageStart"`
+	Values        json.RawMessage `json:"values"`
+}
+
+type link struct {
+	Href string `json:"href"`
+	Name string `json:"name"`
+}
+
+type repository struct {
+	Id       int    `json:"id"`
+	Slug     string `json:"slug"`
+	Name     string `json:"name"`
+	Archived bool   `json:"archived"`
+	Public   bool   `json:"public"`
+	Project  struct {
+		Key string `json:"key"`
+	} `json:"project"`
+	Links struct {
+		Clone []link `json:"clone"`
+		Self  []link `json:"self"`
+	} `json:"links"`
+}
+
+func (r repository) path() string {
+	return r.Project.Key + "/" + r.Slug
+}
+
+// first link with the given name, or the first link at all for an empty name
+func findLink(links []link, name string) string {
+	for _, l := range links {
+		if l.Name == name || name == "" {
+			return l.Href
+		}
+	}
+	return ""
+}
+
+func (b Bitbucket) Host() string {
+	return b.cfg.Host
+}
+
+func (b Bitbucket) Name() string {
+	return b.cfg.Name
+}
+
+func (b Bitbucket) SshAccess() (string, int) {
+	return b.cfg.SSHUser, b.cfg.SSHPort
+}
+
+// request calls the REST API and returns the response body, the statuscode is returned for all responses
+func (b Bitbucket) request(method string, apiPath string, query url.Values) ([]byte, int, error) {
+	u := url.URL{Scheme: "https", Host: b.Host(), Path: apiPath, RawQuery: query.Encode()}
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+b.cfg.ApiToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	if resp.StatusCode >= 300 {
+		return body, resp.StatusCode, fmt.Errorf("Bitbucket API returned statuscode %d", resp.StatusCode)
+	}
+	return body, resp.StatusCode, nil
+}
+
+// getPaged collects the values of all pages for the given API path
+func (b Bitbucket) getPaged(apiPath string, query url.Values) ([]json.RawMessage, error) {
+	var result []json.RawMessage
+	query.Set("limit", "100")
+	start := 0
+	for {
+		say.Info(".")
+		query.Set("start", strconv.Itoa(start))
+		body, _, err := b.request(http.MethodGet, apiPath, query)
+		if err != nil {
+			return nil, err
+		}
+		p := page{}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return nil, err
+		}
+		var values []json.RawMessage
+		if err := json.Unmarshal(p.Values, &values); err != nil {
+			return nil, err
+		}
+		result = append(result, values...)
+		say.Verbose("\nStart: %d, Values: %d", start, len(values))
+		if p.IsLastPage {
+			return result, nil
+		}
+		start = p.NextPageStart
+	}
+}
+
+func (b Bitbucket) listRepositories(apiPath string, query url.Values) []repository {
+	values, err := b.getPaged(apiPath, query)
+	if err != nil {
+		say.Error("Failed retrieving response: %s", err)
+		os.Exit(21) // unknown error behaviour, fail-fast
+	}
+	var result []repository
+	for _, value := range values {
+		r := repository{}
+		if err := json.Unmarshal(value, &r); err != nil {
+			say.Error("Failed parsing repository: %s", err)
+			os.Exit(21)
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+// PartialTopics marks the topics of the listed repositories to be limited to the requested topics
+func (b Bitbucket) PartialTopics() {}
+
+// labels (the Bitbucket topics) are only known for the requested topics, as they are not part of the repository listing
+func (b Bitbucket) labeledPaths(topics []string) map[string][]string {
+	result := map[string][]string{}
+	for _, topic := range topics {
+		query := url.Values{}
+		query.Set("type", "REPOSITORY")
+		for _, r := range b.listRepositories("/rest/api/1.0/labels/"+topic+"/labeled", query) {
+			result[r.path()] = append(result[r.path()], topic)
+		}
+	}
+	return result
+}
+
+// Visibilities of the repositories, which are either private or public
+func (b Bitbucket) Visibilities() []string {
+	return []string{"private", "public"}
+}
+
+func (b Bitbucket) Repositories(options hoster.RequestOptions) []hoster.HosterRepository {
+	say.Info("Retrieving bitbucket repositories")
+	options = hoster.WithoutGroupIds("Bitbucket", options)
+	options = hoster.WithKnownVisibility(b, options)
+	if options.Starred {
+		say.Warn("Bitbucket does not support starred repositories, ignoring filter")
+	}
+	hoster.WarnUnsupported("Bitbucket", options, "visibility", "min-access-level")
+	query := url.Values{}
+	query.Set("permission", permission(options.MinAccessLevel))
+	repositories := b.listRepositories("/rest/api/1.0/repos", query)
+	labels := b.labeledPaths(options.Topics)
+
+	var total int
+	var repos []hoster.HosterRepository
+	for _, r := range repositories {
+		total++
+		if r.Archived && !options.Archived {
+			continue
+		}
+		repo := hoster.HosterRepository{
+			Id:                r.Id,
+			Name:              r.Name,
+			Path:              r.Slug,
+			PathWithNamespace: r.path(),
+			Topics:            labels[r.path()],
+			SshUrl:            findLink(r.Links.Clone, "ssh"),
+			HttpUrl:           findLink(r.Links.Clone, "http"),
+			WebUrl:            findLink(r.Links.Self, ""),
+			Archived:          r.Archived,
+			Visibility:        "private"}
+		if r.Public {
+			repo.Visibility = "public"
+		}
+		if hoster.Matches(options, repo.PathWithNamespace, repo.Topics) && hoster.MatchesAttributes(options, repo) {
+			repos = append(repos, repo)
+		}
+	}
+	say.InfoLn(" %d retrieved (%d filtered)\n", len(repos), total-len(repos))
+	return repos
+}
+
+// permission required for the listed repositories, depending on the minimum access level
+func permission(minAccessLevel string) string {
+	switch minAccessLevel {
+	case "developer":
+		return "REPO_WRITE"
+	case "maintainer", "owner":
+		return "REPO_ADMIN"
+	}
+	return "REPO_READ"
+}
+
+// splits the "PROJECT/repo" remote path, http remotes contain the additional "scm/" prefix
+func splitPath(remotePath string) (string, string) {
+	project, slug, _ := strings.Cut(strings.TrimPrefix(remotePath, "scm/"), "/")
+	return project, slug
+}
+
+func (b Bitbucket) ProjectState(projectPath string) (hoster.CleanupState, error) {
+	say.Verbose("Retrieving bitbucket repository %s", projectPath)
+	project, slug := splitPath(projectPath)
+
+	body, status, err := b.request(http.MethodGet, "/rest/api/1.0/projects/"+project+"/repos/"+slug, url.Values{})
+	if status == http.StatusNotFound {
+		return hoster.Removed, nil
+	}
+	if err != nil {
+		if status == 0 {
+			return hoster.Unknown, errors.New("No response from bitbucket")
+		}
+		return hoster.Unknown, err
+	}
+	r := repository{}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return hoster.Unknown, err
+	}
+	if r.Archived {
+		return hoster.Archived, nil
+	}
+	return hoster.Ok, nil
+}
+
+func (b Bitbucket) Validate(repo model.RepoMeta, optionalManifest bool, optionalContacts bool) []error {
+	return hoster.ValidateManifest(repo, optionalManifest, optionalContacts, b.contactExists)
+}
+
+func (b Bitbucket) contactExists(remotePath, contact string) bool {
+	body, _, err := b.request(http.MethodGet, "/rest/api/1.0/users/"+contact, url.Values{})
+	if err != nil {
+		say.Verbose("Unable to determine user: %s", err)
+		return false
+	}
+	user := struct {
+		Slug   string `json:"slug"`
+		Active bool   `json:"active"`
+	}{}
+	if err := json.Unmarshal(body, &user); err != nil {
+		say.Error("Unable to determine user: %s", err)
+		return false
+	}
+	return user.Slug == contact && user.Active
+}
+
+func (b Bitbucket) DownloadRepoyaml(remotePath string, ref string) (*model.RepoYaml, bool, error) {
+	say.Verbose("Downloading repo.yaml for repository %s", remotePath)
+
+	content, err := downloadFile(b, remotePath, ref)
+	if err != nil {
+		return nil, false, err
+	}
+
+	result := &model.RepoYaml{}
+	err = result.ReadFromByteArray(content)
+	if err != nil {
+		say.Verbose("Invalid content (yaml): %v", result)
+		return nil, false, nil
+	}
+
+	return result, true, nil
+}
+
+func downloadFile(b Bitbucket, remotePath string, ref string) ([]byte, error) {
+	project, slug := splitPath(remotePath)
+	query := url.Values{}
+	if ref != "" {
+		query.Set("at", ref)
+	}
+
+	var content []byte
+	var status int
+	var err error
+
+	for attempts := 0; attempts < b.cfg.DownloadRetryCount; attempts++ {
+		content, status, err = b.request(http.MethodGet, "/rest/api/1.0/projects/"+project+"/repos/"+slug+"/raw/"+model.RepoYamlFilename, query)
+		if err == nil || status == http.StatusNotFound {
+			break
+		}
+		say.Error("Downloading file encountered error (retrying %d): %s", attempts+1, err)
+		time.Sleep(2 * time.Second)
+	}
+
+	if status == http.StatusNotFound {
+		return nil, hoster.ErrManifestMissing
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return content, err
+}
+
+func (b Bitbucket) Apply(repo model.RepoMeta) error {
+	say.Error("Applying the manifest is not supported for bitbucket (%s)", repo.RemotePath)
+	return errors.New("apply is not supported for bitbucket")
+}
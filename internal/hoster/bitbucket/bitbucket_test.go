@@ -0,0 +1,101 @@
+package bitbucket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"repo/internal/config"
+	"repo/internal/hoster"
+	"slices"
+	"testing"
+)
+
+// testHoster creates a hoster using the API of the test server
+func testHoster(t *testing.T, handler http.HandlerFunc) Bitbucket {
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+	serverUrl, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Bitbucket{cfg: config.Hoster{Name: "bitbucket", Host: serverUrl.Host, ApiToken: "token", DownloadRetryCount: 1}, client: server.Client()}
+}
+
+func TestRepositories(t *testing.T) {
+	var starts []string
+	b := testHoster(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/rest/api/1.0/repos":
+			start := r.URL.Query().Get("start")
+			starts = append(starts, start)
+			if start == "0" {
+				w.Write([]byte(`{"isLastPage": false, "nextPageStart": 2, "values": [
+					{"id": 1, "slug": "library", "project": {"key": "PROJ"}},
+					{"id": 2, "slug": "service", "project": {"key": "PROJ"}}]}`))
+				return
+			}
+			w.Write([]byte(`{"isLastPage": true, "values": [{"id": 3, "slug": "legacy", "archived": true, "project": {"key": "PROJ"}}]}`))
+		case "/rest/api/1.0/labels/library/labeled":
+			w.Write([]byte(`{"isLastPage": true, "values": [
+				{"id": 1, "slug": "library", "project": {"key": "PROJ"}},
+				{"id": 3, "slug": "legacy", "project": {"key": "PROJ"}}]}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	})
+
+	repos := b.Repositories(hoster.RequestOptions{Topics: []string{"library"}})
+	if len(repos) != 1 || repos[0].PathWithNamespace != "PROJ/library" || !slices.Equal(repos[0].Topics, []string{"library"}) {
+		t.Errorf("unexpected repositories %v", repos)
+	}
+	if !slices.Equal(starts, []string{"0", "2"}) {
+		t.Errorf("got pages starting at %v, wanted [0 2]", starts)
+	}
+	if repos := b.Repositories(hoster.RequestOptions{Topics: []string{"library"}, Archived: true}); len(repos) != 2 {
+		t.Errorf("unexpected repositories including archived %v", repos)
+	}
+}
+
+func TestProjectState(t *testing.T) {
+	b := testHoster(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/rest/api/1.0/projects/PROJ/repos/service":
+			w.Write([]byte(`{"id": 2, "slug": "service", "project": {"key": "PROJ"}}`))
+		case "/rest/api/1.0/projects/PROJ/repos/legacy":
+			w.Write([]byte(`{"id": 3, "slug": "legacy", "archived": true, "project": {"key": "PROJ"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	expected := map[string]hoster.CleanupState{"PROJ/service": hoster.Ok, "scm/PROJ/legacy": hoster.Archived, "PROJ/removed": hoster.Removed}
+	for projectPath, state := range expected {
+		got, err := b.ProjectState(projectPath)
+		if err != nil || got != state {
+			t.Errorf("got %v (%v), wanted %v for %s", got, err, state, projectPath)
+		}
+	}
+}
+
+func TestDownloadRepoyaml(t *testing.T) {
+	b := testHoster(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/1.0/projects/PROJ/repos/service/raw/repo.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if at := r.URL.Query().Get("at"); at != "refs/heads/main" {
+			t.Errorf("got ref %q, wanted refs/heads/main", at)
+		}
+		w.Write([]byte("type: service\n"))
+	})
+
+	repoYaml, found, err := b.DownloadRepoyaml("PROJ/service", "refs/heads/main")
+	if err != nil || !found || repoYaml.Type != "service" {
+		t.Errorf("unexpected repo.yaml %v (%t, %v)", repoYaml, found, err)
+	}
+	if _, _, err := b.DownloadRepoyaml("PROJ/other", "refs/heads/main"); err != hoster.ErrManifestMissing {
+		t.Errorf("got %v, wanted missing repo.yaml", err)
+	}
+}
@@ -0,0 +1,367 @@
+package gitea
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"repo/internal/config"
+	"repo/internal/hoster"
+	"repo/internal/model"
+	"repo/internal/notification"
+	"repo/internal/say"
+
+	gt "code.gitea.io/sdk/gitea"
+)
+
+const HosterType string = "gitea"
+
+func init() {
+	hoster.Register(HosterType, func(cfg config.Hoster) (hoster.Hoster, error) {
+		return MakeHoster(cfg)
+	})
+}
+
+// MakeHoster creates a hoster for Gitea and compatible forks like Forgejo
+func MakeHoster(cfg config.Hoster) (*Gitea, error) {
+	result := &Gitea{cfg: cfg}
+	if cfg.Host == "" {
+		return result, fmt.Errorf("the Gitea host has to be set (hoster %s)", cfg.Name)
+	}
+	if cfg.ApiToken == "" {
+		return result, fmt.Errorf("the Gitea API-token has to be set (hoster %s)", cfg.Name)
+	}
+
+	var errClient error
+	// version checks are disabled, as Forgejo reports its own version scheme
+	result.client, errClient = gt.NewClient("https://"+result.Host(), gt.SetToken(cfg.ApiToken), gt.SetGiteaVersion(""))
+	if errClient != nil {
+		return nil, errClient
+	}
+	return result, nil
+}
+
+type Gitea struct {
+	cfg    config.Hoster
+	client *gt.Client
+}
+
+func (g Gitea) Host() string {
+	return g.cfg.Host
+}
+
+func (g Gitea) Name() string {
+	return g.cfg.Name
+}
+
+func (g Gitea) SshAccess() (string, int) {
+	return g.cfg.SSHUser, g.cfg.SSHPort
+}
+
+func (g Gitea) Repositories(options hoster.RequestOptions) []hoster.HosterRepository {
+	say.Info("Retrieving gitea repositories")
+	options = hoster.WithoutGroupIds("Gitea", options)
+	options = hoster.WithKnownVisibility(g, options)
+	var total int
+	var repos []hoster.HosterRepository
+	for _, repository := range g.listRepositories(options) {
+		total++
+		if (repository.Archived && !options.Archived) || repository.Empty {
+			continue
+		}
+		repo := hoster.HosterRepository{
+			Id:                int(repository.ID),
+			Name:              repository.Name,
+			Path:              repository.Name,
+			PathWithNamespace: repository.FullName,
+			Topics:            repository.Topics,
+			SshUrl:            repository.SSHURL,
+			HttpUrl:           repository.CloneURL,
+			WebUrl:            repository.HTMLURL,
+			Archived:          repository.Archived,
+			Visibility:        visibility(repository),
+			Fork:              repository.Fork,
+			LastActivity:      repository.Updated,
+			DefaultBranch:     repository.DefaultBranch}
+		if hoster.Matches(options, repo.PathWithNamespace, repo.Topics) && hoster.MatchesAttributes(options, repo) &&
+			hoster.HasAccessLevel(options.MinAccessLevel, accessLevel(repository.Permissions)) {
+			repos = append(repos, repo)
+		}
+	}
+	say.InfoLn(" %d retrieved (%d filtered)\n", len(repos), total-len(repos))
+	return repos
+}
+
+// Visibilities of the repositories, the api only distinguishes private and public repositories
+func (g Gitea) Visibilities() []string {
+	return []string{"private", "public"}
+}
+
+// the api only distinguishes private and public repositories
+func visibility(repository *gt.Repository) string {
+	if repository.Private {
+		return "private"
+	}
+	return "public"
+}
+
+// accessLevel maps the repository permissions of the user to the access levels of the filter
+func accessLevel(permissions *gt.Permission) string {
+	switch {
+	case permissions == nil:
+		return ""
+	case permissions.Admin:
+		return "owner"
+	case permissions.Push:
+		return "developer"
+	case permissions.Pull:
+		return "guest"
+	}
+	return ""
+}
+
+// listRepositories returns the repositories the user has access to. Starred repositories are searched and limited
+// to the ones the user has access to, owned repositories and the topics are matched afterwards.
+func (g Gitea) listRepositories(options hoster.RequestOptions) []*gt.Repository {
+	var user *gt.User
+	if options.Starred || options.Owned {
+		var err error
+		if user, _, err = g.client.GetMyUserInfo(); err != nil {
+			say.Error("Failed retrieving user: %s", err)
+			os.Exit(21) // unknown error behaviour, fail-fast
+		}
+	}
+	var starred map[int64]bool
+	if options.Starred {
+		starred = map[int64]bool{}
+		searchOptions := gt.SearchRepoOptions{StarredByUserID: user.ID}
+		for _, repository := range listPages(func(listOptions gt.ListOptions) ([]*gt.Repository, *gt.Response, error) {
+			searchOptions.ListOptions = listOptions
+			return g.client.SearchRepos(searchOptions)
+		}) {
+			starred[repository.ID] = true
+		}
+	}
+
+	var result []*gt.Repository
+	for _, repository := range listPages(func(listOptions gt.ListOptions) ([]*gt.Repository, *gt.Response, error) {
+		return g.client.ListMyRepos(gt.ListReposOptions{ListOptions: listOptions})
+	}) {
+		if starred != nil && !starred[repository.ID] {
+			continue
+		}
+		if options.Owned && (repository.Owner == nil || repository.Owner.ID != user.ID) {
+			continue
+		}
+		result = append(result, repository)
+	}
+	return result
+}
+
+// listPages loops through all pages of the listing
+func listPages(list func(gt.ListOptions) ([]*gt.Repository, *gt.Response, error)) []*gt.Repository {
+	var result []*gt.Repository
+	listOptions := gt.ListOptions{Page: 1, PageSize: 50}
+	for listOptions.Page != 0 {
+		say.Info(".")
+		repositoriesPage, response, err := list(listOptions)
+		if err != nil {
+			say.Error("Failed retrieving response: %s", err)
+			os.Exit(21) // unknown error behaviour, fail-fast
+		}
+		result = append(result, repositoriesPage...)
+		say.Verbose("\nPage: %d, Repositories: %d, Statuscode: %d", listOptions.Page, len(repositoriesPage), response.StatusCode)
+		listOptions.Page = response.NextPage
+	}
+	return result
+}
+
+// splits the "owner/repository" remote path
+func splitPath(remotePath string) (string, string) {
+	owner, repository, _ := strings.Cut(remotePath, "/")
+	return owner, repository
+}
+
+func (g Gitea) ProjectState(projectPath string) (hoster.CleanupState, error) {
+	say.Verbose("Retrieving gitea repository %s", projectPath)
+	owner, name := splitPath(projectPath)
+
+	repository, response, err := g.client.GetRepo(owner, name)
+	if err != nil {
+		if response == nil {
+			return hoster.Unknown, errors.New("No response from gitea")
+		}
+		if response.StatusCode == http.StatusNotFound {
+			return hoster.Removed, nil
+		}
+		return hoster.Unknown, err
+	}
+	if repository.Archived {
+		return hoster.Archived, nil
+	}
+	return hoster.Ok, nil
+}
+
+// Locate resolves renamed and transferred repositories by their ID. Without ID the redirect of Gitea for the previous
+// path is used, which is lost once the path is reused.
+func (g Gitea) Locate(projectPath string, id int) (*hoster.HosterRepository, error) {
+	say.Verbose("Locating gitea repository %s (%d)", projectPath, id)
+	var repository *gt.Repository
+	var err error
+	if id > 0 {
+		repository, _, err = g.client.GetRepoByID(int64(id))
+	} else {
+		owner, name := splitPath(projectPath)
+		repository, _, err = g.client.GetRepo(owner, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &hoster.HosterRepository{
+		Id:                int(repository.ID),
+		Name:              repository.Name,
+		Path:              repository.Name,
+		PathWithNamespace: repository.FullName,
+		Topics:            repository.Topics,
+		SshUrl:            repository.SSHURL,
+		HttpUrl:           repository.CloneURL,
+		WebUrl:            repository.HTMLURL,
+		Archived:          repository.Archived}, nil
+}
+
+func (g Gitea) Validate(repo model.RepoMeta, optionalManifest bool, optionalContacts bool) []error {
+	errs := hoster.ValidateManifest(repo, optionalManifest, optionalContacts, g.contactExists)
+	if repo.RepoYaml == nil || !repo.RepoYamlValid {
+		return errs
+	}
+	// gitea features
+	gf := repo.RepoYaml.Gitea
+	allowed := []string{string(gt.MergeStyleMerge), string(gt.MergeStyleRebase), string(gt.MergeStyleRebaseMerge), string(gt.MergeStyleSquash)}
+	if gf.DefaultMergeStyle != nil && !slices.Contains(allowed, *gf.DefaultMergeStyle) {
+		errs = append(errs, errors.New(fmt.Sprintf("DefaultMergeStyle must be one of: %s", allowed)))
+	}
+	return errs
+}
+
+func (g Gitea) contactExists(remotePath, contact string) bool {
+	owner, name := splitPath(remotePath)
+	collaborator, _, err := g.client.IsCollaborator(owner, name, contact)
+	if err != nil {
+		say.Error("Unable to determine user: %s", err)
+		return false
+	}
+	return collaborator
+}
+
+func (g Gitea) DownloadRepoyaml(remotePath string, ref string) (*model.RepoYaml, bool, error) {
+	say.Verbose("Downloading repo.yaml for repository %s", remotePath)
+
+	content, err := downloadFile(g, remotePath, strings.TrimPrefix(ref, "refs/heads/"))
+	if err != nil {
+		return nil, false, err
+	}
+
+	result := &model.RepoYaml{}
+	err = result.ReadFromByteArray(content)
+	if err != nil {
+		say.Verbose("Invalid content (yaml): %v", result)
+		return nil, false, nil
+	}
+
+	return result, true, nil
+}
+
+func downloadFile(g Gitea, remotePath string, ref string) ([]byte, error) {
+	owner, name := splitPath(remotePath)
+
+	var content []byte
+	var response *gt.Response
+	var err error
+
+	for attempts := 0; attempts < g.cfg.DownloadRetryCount; attempts++ {
+		content, response, err = g.client.GetFile(owner, name, ref, model.RepoYamlFilename)
+		if err == nil || (response != nil && response.StatusCode == http.StatusNotFound) {
+			break
+		}
+		say.Error("Downloading file encountered error (retrying %d): %s", attempts+1, err)
+		time.Sleep(2 * time.Second)
+	}
+
+	if response != nil && response.StatusCode == http.StatusNotFound {
+		return nil, hoster.ErrManifestMissing
+	}
+	if err != nil {
+		return nil, err
+	}
+	if response == nil {
+		return nil, errors.New("No gitea server response")
+	}
+
+	return content, err
+}
+
+// giteaTopic converts the topic to the gitea format (lowercase letters, numbers, hyphens and dots, starting with a
+// letter or number)
+func giteaTopic(topic string) string {
+	result := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '.' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(topic))
+	return strings.TrimLeft(result, "-.")
+}
+
+func (g Gitea) Apply(repo model.RepoMeta) error {
+	say.InfoLn("Apply %s", repo.Name)
+	owner, name := splitPath(repo.RemotePath)
+
+	// topics
+	topics := []string{}
+	for _, topic := range hoster.ManifestTopics(repo.RepoYaml) {
+		topics = append(topics, giteaTopic(topic))
+	}
+
+	// description and gitea features
+	gf := repo.RepoYaml.Gitea
+	ero := gt.EditRepoOption{
+		Description:                   repo.RepoYaml.Description,
+		HasWiki:                       gf.HasWiki,
+		HasIssues:                     gf.HasIssues,
+		HasProjects:                   gf.HasProjects,
+		HasPullRequests:               gf.HasPullRequests,
+		AllowMerge:                    gf.AllowMerge,
+		AllowRebase:                   gf.AllowRebase,
+		AllowRebaseMerge:              gf.AllowRebaseMerge,
+		AllowSquash:                   gf.AllowSquash,
+		AllowFastForwardOnlyMerge:     gf.AllowFastForwardOnlyMerge,
+		DefaultDeleteBranchAfterMerge: gf.DefaultDeleteBranchAfterMerge,
+	}
+	if gf.DefaultMergeStyle != nil {
+		dms := gt.MergeStyle(*gf.DefaultMergeStyle)
+		ero.DefaultMergeStyle = &dms
+	}
+
+	if config.Values.Options.DryRun {
+		hoster.PrintPlannedEdit(repo.RemotePath, "SetRepoTopics", topics)
+		hoster.PrintPlannedEdit(repo.RemotePath, "EditRepo", ero)
+		return nil
+	}
+	_, err := g.client.SetRepoTopics(owner, name, topics)
+	if err != nil {
+		notification.NotifyInvalidRepository(repo.RemotePath, err.Error())
+		say.Error("%s", err)
+		return err
+	}
+
+	if _, _, err := g.client.EditRepo(owner, name, ero); err != nil {
+		notification.NotifyInvalidRepository(repo.RemotePath, err.Error())
+		say.Error("%s", err)
+		return fmt.Errorf("unable to edit %s: %w", repo.RemotePath, err)
+	}
+	return nil
+}
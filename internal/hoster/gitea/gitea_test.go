@@ -0,0 +1,111 @@
+package gitea
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"repo/internal/config"
+	"repo/internal/hoster"
+	"slices"
+	"testing"
+
+	gt "code.gitea.io/sdk/gitea"
+)
+
+// testHoster creates a hoster using the API of the test server
+func testHoster(t *testing.T, handler http.HandlerFunc) Gitea {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client, err := gt.NewClient(server.URL, gt.SetToken("token"), gt.SetGiteaVersion(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Gitea{cfg: config.Hoster{Name: "forge", Host: "forge.acme.corp", DownloadRetryCount: 1}, client: client}
+}
+
+func notFound(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte(`{"message": "Not Found"}`))
+}
+
+func TestRepositories(t *testing.T) {
+	var pages []string
+	g := testHoster(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/user/repos" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		w.Header().Set("Content-Type", "application/json")
+		if page == "1" {
+			w.Header().Set("Link", `<http://`+r.Host+`/api/v1/user/repos?page=2>; rel="next"`)
+			w.Write([]byte(`[{"id": 1, "name": "library", "full_name": "acme/library", "topics": ["library"]},
+				{"id": 2, "name": "service", "full_name": "acme/service", "topics": ["service"]},
+				{"id": 3, "name": "empty", "full_name": "acme/empty", "topics": ["library"], "empty": true}]`))
+			return
+		}
+		w.Write([]byte(`[{"id": 4, "name": "legacy", "full_name": "acme/legacy", "topics": ["library"], "archived": true}]`))
+	})
+
+	repos := g.Repositories(hoster.RequestOptions{Topics: []string{"library"}})
+	if len(repos) != 1 || repos[0].PathWithNamespace != "acme/library" {
+		t.Errorf("unexpected repositories %v", repos)
+	}
+	if !slices.Equal(pages, []string{"1", "2"}) {
+		t.Errorf("got pages %v, wanted [1 2]", pages)
+	}
+	if repos := g.Repositories(hoster.RequestOptions{Topics: []string{"library"}, Archived: true}); len(repos) != 2 {
+		t.Errorf("unexpected repositories including archived %v", repos)
+	}
+}
+
+func TestProjectState(t *testing.T) {
+	g := testHoster(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/repos/acme/service":
+			w.Write([]byte(`{"id": 2, "full_name": "acme/service"}`))
+		case "/api/v1/repos/acme/legacy":
+			w.Write([]byte(`{"id": 4, "full_name": "acme/legacy", "archived": true}`))
+		default:
+			notFound(w)
+		}
+	})
+
+	expected := map[string]hoster.CleanupState{"acme/service": hoster.Ok, "acme/legacy": hoster.Archived, "acme/removed": hoster.Removed}
+	for projectPath, state := range expected {
+		got, err := g.ProjectState(projectPath)
+		if err != nil || got != state {
+			t.Errorf("got %v (%v), wanted %v for %s", got, err, state, projectPath)
+		}
+	}
+}
+
+func TestDownloadRepoyaml(t *testing.T) {
+	g := testHoster(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/acme/service/raw/repo.yaml" {
+			notFound(w)
+			return
+		}
+		if ref := r.URL.Query().Get("ref"); ref != "main" {
+			t.Errorf("got ref %q, wanted main", ref)
+		}
+		w.Write([]byte("type: service\n"))
+	})
+
+	repoYaml, found, err := g.DownloadRepoyaml("acme/service", "refs/heads/main")
+	if err != nil || !found || repoYaml.Type != "service" {
+		t.Errorf("unexpected repo.yaml %v (%t, %v)", repoYaml, found, err)
+	}
+	if _, _, err := g.DownloadRepoyaml("acme/other", "refs/heads/main"); err != hoster.ErrManifestMissing {
+		t.Errorf("got %v, wanted missing repo.yaml", err)
+	}
+}
+
+func TestGiteaTopic(t *testing.T) {
+	if got := giteaTopic("My_Topic"); got != "my-topic" {
+		t.Errorf("got %s, wanted my-topic", got)
+	}
+	if got := giteaTopic("_internal.lib"); got != "internal.lib" {
+		t.Errorf("got %s, wanted internal.lib", got)
+	}
+}
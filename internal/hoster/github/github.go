@@ -1,8 +1,323 @@
 package github
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"repo/internal/config"
+	"repo/internal/hoster"
+	"repo/internal/model"
+	"repo/internal/notification"
+	"repo/internal/say"
+
+	gh "github.com/google/go-github/v72/github"
+)
+
+const HosterType string = "github"
+
+func init() {
+	hoster.Register(HosterType, func(cfg config.Hoster) (hoster.Hoster, error) {
+		return MakeHoster(cfg)
+	})
+}
+
+func MakeHoster(cfg config.Hoster) (*Github, error) {
+	if cfg.Host == "" {
+		cfg.Host = "github.com"
+	}
+	result := &Github{cfg: cfg}
+	if cfg.ApiToken == "" {
+		return result, fmt.Errorf("the Github API-token has to be set (hoster %s)", cfg.Name)
+	}
+
+	result.client = gh.NewClient(nil).WithAuthToken(cfg.ApiToken)
+	if result.Host() != "github.com" {
+		// Github Enterprise Server
+		var errClient error
+		result.client, errClient = result.client.WithEnterpriseURLs("https://"+result.Host()+"/api/v3/", "https://"+result.Host()+"/api/uploads/")
+		if errClient != nil {
+			return nil, errClient
+		}
+	}
+	return result, nil
+}
+
 type Github struct {
+	cfg    config.Hoster
+	client *gh.Client
 }
 
 func (g Github) Host() string {
-	return "github.com"
+	return g.cfg.Host
+}
+
+func (g Github) Name() string {
+	return g.cfg.Name
+}
+
+func (g Github) SshAccess() (string, int) {
+	return g.cfg.SSHUser, g.cfg.SSHPort
+}
+
+func (g Github) Repositories(options hoster.RequestOptions) []hoster.HosterRepository {
+	say.Info("Retrieving github repositories")
+	options = hoster.WithoutGroupIds("Github", options)
+	if options.Owned && options.Starred {
+		say.Warn("\nGithub does not support --owned together with starred repositories, ignoring filter")
+	}
+	var total int
+	var repos []hoster.HosterRepository
+	for _, repository := range g.listRepositories(options.Starred, options.Owned) {
+		total++
+		if (repository.GetArchived() && !options.Archived) || repository.GetDisabled() {
+			continue
+		}
+		repo := hoster.HosterRepository{
+			Id:                int(repository.GetID()),
+			Name:              repository.GetName(),
+			Path:              repository.GetName(),
+			PathWithNamespace: repository.GetFullName(),
+			Topics:            repository.Topics,
+			SshUrl:            repository.GetSSHURL(),
+			HttpUrl:           repository.GetCloneURL(),
+			WebUrl:            repository.GetHTMLURL(),
+			Archived:          repository.GetArchived(),
+			Visibility:        repository.GetVisibility(),
+			Fork:              repository.GetFork(),
+			LastActivity:      repository.GetPushedAt().Time,
+			DefaultBranch:     repository.GetDefaultBranch()}
+		if hoster.Matches(options, repo.PathWithNamespace, repo.Topics) && hoster.MatchesAttributes(options, repo) &&
+			hoster.HasAccessLevel(options.MinAccessLevel, accessLevel(repository.GetPermissions())) {
+			repos = append(repos, repo)
+		}
+	}
+	say.InfoLn(" %d retrieved (%d filtered)\n", len(repos), total-len(repos))
+	return repos
+}
+
+// accessLevel maps the repository permissions of the user to the access levels of the filter
+func accessLevel(permissions map[string]bool) string {
+	levels := []struct{ permission, level string }{
+		{"admin", "owner"}, {"maintain", "maintainer"}, {"push", "developer"}, {"triage", "reporter"}, {"pull", "guest"},
+	}
+	for _, l := range levels {
+		if permissions[l.permission] {
+			return l.level
+		}
+	}
+	return ""
+}
+
+// listRepositories returns all repositories the user owns, collaborates on or can access as organization member (or only the starred or owned ones)
+func (g Github) listRepositories(starred bool, owned bool) []*gh.Repository {
+	affiliation := "owner,collaborator,organization_member"
+	if owned {
+		affiliation = "owner"
+	}
+	var result []*gh.Repository
+	listOptions := gh.ListOptions{PerPage: 100, Page: 1}
+	for listOptions.Page != 0 { // Loop through all pages and get list of repositories
+		say.Info(".")
+		var response *gh.Response
+		var err error
+		if starred {
+			var starredPage []*gh.StarredRepository
+			starredPage, response, err = g.client.Activity.ListStarred(context.Background(), "", &gh.ActivityListStarredOptions{ListOptions: listOptions})
+			for _, s := range starredPage {
+				result = append(result, s.Repository)
+			}
+		} else {
+			var repositoriesPage []*gh.Repository
+			repositoriesPage, response, err = g.client.Repositories.ListByAuthenticatedUser(context.Background(), &gh.RepositoryListByAuthenticatedUserOptions{
+				Affiliation: affiliation,
+				ListOptions: listOptions,
+			})
+			result = append(result, repositoriesPage...)
+		}
+		if err != nil {
+			say.Error("Failed retrieving response: %s", err)
+			os.Exit(21) // unknown error behaviour, fail-fast
+		}
+		say.Verbose("\nPage: %d, Repositories: %d, Statuscode: %d", listOptions.Page, len(result), response.StatusCode)
+		listOptions.Page = response.NextPage
+	}
+	return result
+}
+
+// splits the "owner/repository" remote path
+func splitPath(remotePath string) (string, string) {
+	owner, repository, _ := strings.Cut(remotePath, "/")
+	return owner, repository
+}
+
+func (g Github) ProjectState(projectPath string) (hoster.CleanupState, error) {
+	say.Verbose("Retrieving github repository %s", projectPath)
+	owner, name := splitPath(projectPath)
+
+	repository, response, err := g.client.Repositories.Get(context.Background(), owner, name)
+	if err != nil {
+		if response == nil {
+			return hoster.Unknown, errors.New("No response from github")
+		}
+		if response.StatusCode == http.StatusNotFound {
+			return hoster.Removed, nil
+		}
+		return hoster.Unknown, err
+	}
+	if repository.GetArchived() {
+		return hoster.Archived, nil
+	}
+	return hoster.Ok, nil
+}
+
+// Locate resolves renamed and transferred repositories by their ID. Without ID the redirect of Github for the previous
+// path is used, which is lost once the path is reused.
+func (g Github) Locate(projectPath string, id int) (*hoster.HosterRepository, error) {
+	say.Verbose("Locating github repository %s (%d)", projectPath, id)
+	var repository *gh.Repository
+	var err error
+	if id > 0 {
+		repository, _, err = g.client.Repositories.GetByID(context.Background(), int64(id))
+	} else {
+		owner, name := splitPath(projectPath)
+		repository, _, err = g.client.Repositories.Get(context.Background(), owner, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &hoster.HosterRepository{
+		Id:                int(repository.GetID()),
+		Name:              repository.GetName(),
+		Path:              repository.GetName(),
+		PathWithNamespace: repository.GetFullName(),
+		Topics:            repository.Topics,
+		SshUrl:            repository.GetSSHURL(),
+		HttpUrl:           repository.GetCloneURL(),
+		WebUrl:            repository.GetHTMLURL(),
+		Archived:          repository.GetArchived()}, nil
+}
+
+func (g Github) Validate(repo model.RepoMeta, optionalManifest bool, optionalContacts bool) []error {
+	return hoster.ValidateManifest(repo, optionalManifest, optionalContacts, g.contactExists)
+}
+
+func (g Github) contactExists(remotePath, contact string) bool {
+	owner, name := splitPath(remotePath)
+	collaborator, _, err := g.client.Repositories.IsCollaborator(context.Background(), owner, name, contact)
+	if err != nil {
+		say.Error("Unable to determine user: %s", err)
+		return false
+	}
+	return collaborator
+}
+
+func (g Github) DownloadRepoyaml(remotePath string, ref string) (*model.RepoYaml, bool, error) {
+	say.Verbose("Downloading repo.yaml for repository %s", remotePath)
+
+	file, err := downloadFile(g, remotePath, ref)
+	if err != nil {
+		return nil, false, err
+	}
+
+	content, errDecode := file.GetContent()
+	if errDecode != nil {
+		return nil, false, errors.New(fmt.Sprintf("Invalid content: %s", errDecode))
+	}
+
+	result := &model.RepoYaml{}
+	err = result.ReadFromString(content)
+	if err != nil {
+		say.Verbose("Invalid content (yaml): %v", result)
+		return nil, false, nil
+	}
+
+	return result, true, nil
+}
+
+func downloadFile(g Github, remotePath string, ref string) (*gh.RepositoryContent, error) {
+	owner, name := splitPath(remotePath)
+	rcgo := &gh.RepositoryContentGetOptions{
+		Ref: ref,
+	}
+
+	var file *gh.RepositoryContent
+	var response *gh.Response
+	var err error
+
+	for attempts := 0; attempts < g.cfg.DownloadRetryCount; attempts++ {
+		file, _, response, err = g.client.Repositories.GetContents(context.Background(), owner, name, model.RepoYamlFilename, rcgo)
+		if err == nil || (response != nil && response.StatusCode == http.StatusNotFound) {
+			break
+		}
+		say.Error("Downloading file encountered error (retrying %d): %s", attempts+1, err)
+		time.Sleep(2 * time.Second)
+	}
+
+	if response != nil && response.StatusCode == http.StatusNotFound {
+		return nil, hoster.ErrManifestMissing
+	}
+	if err != nil {
+		return nil, err
+	}
+	if response == nil {
+		return nil, errors.New("No github server response")
+	}
+	if file == nil {
+		return nil, errors.New("repo.yaml is not a file")
+	}
+
+	return file, err
+}
+
+// ghTopic converts the topic to the github format (lowercase letters, numbers and hyphens)
+func ghTopic(topic string) string {
+	return strings.ReplaceAll(strings.ToLower(topic), "_", "-")
+}
+
+func (g Github) Apply(repo model.RepoMeta) error {
+	say.InfoLn("Apply %s", repo.Name)
+	owner, name := splitPath(repo.RemotePath)
+
+	// topics
+	topics := []string{}
+	for _, topic := range hoster.ManifestTopics(repo.RepoYaml) {
+		topics = append(topics, ghTopic(topic))
+	}
+
+	// description and github features
+	gf := repo.RepoYaml.Github
+	edit := &gh.Repository{
+		Description:         repo.RepoYaml.Description,
+		HasWiki:             gf.HasWiki,
+		HasIssues:           gf.HasIssues,
+		HasProjects:         gf.HasProjects,
+		AllowForking:        gf.AllowForking,
+		AllowMergeCommit:    gf.AllowMergeCommit,
+		AllowSquashMerge:    gf.AllowSquashMerge,
+		AllowRebaseMerge:    gf.AllowRebaseMerge,
+		DeleteBranchOnMerge: gf.DeleteBranchOnMerge,
+	}
+
+	if config.Values.Options.DryRun {
+		hoster.PrintPlannedEdit(repo.RemotePath, "ReplaceAllTopics", topics)
+		hoster.PrintPlannedEdit(repo.RemotePath, "Edit", edit)
+		return nil
+	}
+	_, _, err := g.client.Repositories.ReplaceAllTopics(context.Background(), owner, name, topics)
+	if err != nil {
+		notification.NotifyInvalidRepository(repo.RemotePath, err.Error())
+		say.Error("%s", err)
+		return err
+	}
+	if _, _, err := g.client.Repositories.Edit(context.Background(), owner, name, edit); err != nil {
+		notification.NotifyInvalidRepository(repo.RemotePath, err.Error())
+		say.Error("%s", err)
+		return fmt.Errorf("unable to edit %s: %w", repo.RemotePath, err)
+	}
+	return nil
 }
@@ -0,0 +1,101 @@
+package github
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"repo/internal/config"
+	"repo/internal/hoster"
+	"slices"
+	"testing"
+
+	gh "github.com/google/go-github/v72/github"
+)
+
+// testHoster creates a hoster using the API of the test server
+func testHoster(t *testing.T, handler http.HandlerFunc) Github {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client := gh.NewClient(nil)
+	baseUrl, err := url.Parse(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.BaseURL = baseUrl
+	return Github{cfg: config.Hoster{Name: "github", Host: "github.com", DownloadRetryCount: 1}, client: client}
+}
+
+func TestRepositories(t *testing.T) {
+	var pages []string
+	g := testHoster(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/user/repos" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		w.Header().Set("Content-Type", "application/json")
+		if page == "1" {
+			w.Header().Set("Link", `<http://`+r.Host+`/user/repos?page=2>; rel="next"`)
+			w.Write([]byte(`[{"id": 1, "name": "library", "full_name": "acme/library", "topics": ["library"]},
+				{"id": 2, "name": "service", "full_name": "acme/service", "topics": ["service"]}]`))
+			return
+		}
+		w.Write([]byte(`[{"id": 3, "name": "legacy", "full_name": "acme/legacy", "topics": ["library"], "archived": true}]`))
+	})
+
+	repos := g.Repositories(hoster.RequestOptions{Topics: []string{"library"}})
+	if len(repos) != 1 || repos[0].PathWithNamespace != "acme/library" {
+		t.Errorf("unexpected repositories %v", repos)
+	}
+	if !slices.Equal(pages, []string{"1", "2"}) {
+		t.Errorf("got pages %v, wanted [1 2]", pages)
+	}
+	if repos := g.Repositories(hoster.RequestOptions{Topics: []string{"library"}, Archived: true}); len(repos) != 2 {
+		t.Errorf("unexpected repositories including archived %v", repos)
+	}
+}
+
+func TestProjectState(t *testing.T) {
+	g := testHoster(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/repos/acme/service":
+			w.Write([]byte(`{"id": 2, "full_name": "acme/service"}`))
+		case "/repos/acme/legacy":
+			w.Write([]byte(`{"id": 3, "full_name": "acme/legacy", "archived": true}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Not Found"}`))
+		}
+	})
+
+	expected := map[string]hoster.CleanupState{"acme/service": hoster.Ok, "acme/legacy": hoster.Archived, "acme/removed": hoster.Removed}
+	for projectPath, state := range expected {
+		got, err := g.ProjectState(projectPath)
+		if err != nil || got != state {
+			t.Errorf("got %v (%v), wanted %v for %s", got, err, state, projectPath)
+		}
+	}
+}
+
+func TestDownloadRepoyaml(t *testing.T) {
+	g := testHoster(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/repos/acme/service/contents/repo.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Not Found"}`))
+			return
+		}
+		content := base64.StdEncoding.EncodeToString([]byte("type: service\n"))
+		w.Write([]byte(`{"type": "file", "encoding": "base64", "content": "` + content + `"}`))
+	})
+
+	repoYaml, found, err := g.DownloadRepoyaml("acme/service", "")
+	if err != nil || !found || repoYaml.Type != "service" {
+		t.Errorf("unexpected repo.yaml %v (%t, %v)", repoYaml, found, err)
+	}
+	if _, _, err := g.DownloadRepoyaml("acme/other", ""); err != hoster.ErrManifestMissing {
+		t.Errorf("got %v, wanted missing repo.yaml", err)
+	}
+}
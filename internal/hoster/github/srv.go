@@ -0,0 +1,54 @@
+package github
+
+import (
+	"errors"
+	"net/http"
+	"repo/internal/config"
+	"repo/internal/hoster"
+
+	gh "github.com/google/go-github/v72/github"
+)
+
+type WebHookPush struct {
+	Ref     string
+	Project struct {
+		Name              string
+		PathWithNamespace string
+		DefaultBranch     string
+	}
+}
+
+func HandleWebhookGithub(w http.ResponseWriter, r *http.Request, cfg config.Hoster) (hoster.Hoster, *WebHookPush, error) {
+	// docs: https://docs.github.com/en/webhooks/using-webhooks/validating-webhook-deliveries
+	payload, err := gh.ValidatePayload(r, []byte(cfg.SecretToken))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	eventType := gh.WebHookType(r)
+	if eventType != "push" {
+		w.Write([]byte("ignored"))
+		return nil, nil, nil
+	}
+
+	hoster, err := MakeHoster(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	event, err := gh.ParseWebHook(eventType, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	pushEvent, ok := event.(*gh.PushEvent)
+	if !ok {
+		return nil, nil, errors.New("unexpected webhook payload")
+	}
+
+	push := WebHookPush{Ref: pushEvent.GetRef()}
+	push.Project.Name = pushEvent.GetRepo().GetName()
+	push.Project.PathWithNamespace = pushEvent.GetRepo().GetFullName()
+	push.Project.DefaultBranch = pushEvent.GetRepo().GetDefaultBranch()
+
+	return hoster, &push, nil
+}
@@ -4,9 +4,11 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
-	"regexp"
+	"regexp/syntax"
 	"slices"
+	"strings"
 	"time"
 
 	"repo/internal/config"
@@ -19,14 +21,25 @@ import (
 	gg "github.com/xanzy/go-gitlab"
 )
 
-func MakeHoster() (*Gitlab, error) {
-	result := &Gitlab{}
-	if config.Values.Gitlab.ApiToken == "" {
-		return result, errors.New("the Gitlab API-token has to be set")
+const HosterType string = "gitlab"
+
+func init() {
+	hoster.Register(HosterType, func(cfg config.Hoster) (hoster.Hoster, error) {
+		return MakeHoster(cfg)
+	})
+}
+
+func MakeHoster(cfg config.Hoster) (*Gitlab, error) {
+	if cfg.Host == "" {
+		cfg.Host = "gitlab.com"
+	}
+	result := &Gitlab{cfg: cfg}
+	if cfg.ApiToken == "" {
+		return result, fmt.Errorf("the Gitlab API-token has to be set (hoster %s)", cfg.Name)
 	}
 
 	var errClient error
-	result.client, errClient = gg.NewClient(config.Values.Gitlab.ApiToken, gitlab.WithBaseURL("https://"+result.Host()))
+	result.client, errClient = gg.NewClient(cfg.ApiToken, gitlab.WithBaseURL("https://"+result.Host()))
 	if errClient != nil {
 		return nil, errClient
 	}
@@ -34,28 +47,185 @@ func MakeHoster() (*Gitlab, error) {
 }
 
 type Gitlab struct {
+	cfg    config.Hoster
 	client *gg.Client
 }
 
 func (g Gitlab) Host() string {
-	return config.Values.Gitlab.Host
+	return g.cfg.Host
+}
+
+func (g Gitlab) Name() string {
+	return g.cfg.Name
+}
+
+func (g Gitlab) SshAccess() (string, int) {
+	return g.cfg.SSHUser, g.cfg.SSHPort
 }
 
 func (g Gitlab) Repositories(options hoster.RequestOptions) []hoster.HosterRepository {
 	say.Info("Retrieving gitlab projects")
+	options.Groups = g.resolveGroups(options.Groups)
+	var projects []*gg.Project
+	if len(options.Groups) > 0 {
+		projects = g.listGroupProjects(options.Groups, false, options)
+	} else if groups, scoped := groupScopes(options.IncludePatterns); scoped {
+		projects = g.listGroupProjects(groups, true, options)
+	} else {
+		projects = g.listProjects(options)
+	}
+
+	var repos []hoster.HosterRepository
+	for _, project := range projects {
+		// the server side filtering is only a preselection, the patterns and topics are still matched
+		repo := toRepository(project)
+		if matches(options, project.PathWithNamespace, project.TagList, project.RepositoryAccessLevel) && hoster.MatchesAttributes(options, repo) {
+			repos = append(repos, repo)
+		}
+	}
+	say.InfoLn(" %d retrieved (%d filtered)\n", len(repos), len(projects)-len(repos))
+	return repos
+}
+
+func toRepository(project *gg.Project) hoster.HosterRepository {
+	//_, pathWithoutNamespace, _ := strings.Cut(project.PathWithNamespace, "/")
+	//say.Info("\npath: %s (was: %s)", rootlessPath, project.PathWithNamespace)
+	var lastActivity time.Time
+	if project.LastActivityAt != nil {
+		lastActivity = *project.LastActivityAt
+	}
+	return hoster.HosterRepository{
+		Id:                project.ID,
+		Name:              project.Name,
+		Path:              project.Path,
+		PathWithNamespace: project.PathWithNamespace,
+		//PathWithoutNamespace: pathWithoutNamespace,
+		Topics:        project.TagList,
+		SshUrl:        project.SSHURLToRepo,
+		HttpUrl:       project.HTTPURLToRepo,
+		WebUrl:        project.WebURL,
+		Archived:      project.Archived,
+		Visibility:    string(project.Visibility),
+		Fork:          project.ForkedFromProject != nil,
+		LastActivity:  lastActivity,
+		DefaultBranch: project.DefaultBranch}
+}
+
+// resolveGroups replaces the group IDs by the full path of the groups, so the projects can be matched by their path
+func (g Gitlab) resolveGroups(groups []string) []string {
+	var result []string
+	for _, group := range groups {
+		if !hoster.IsGroupId(group) {
+			result = append(result, group)
+			continue
+		}
+		resolved, response, err := g.client.Groups.GetGroup(group, &gg.GetGroupOptions{WithProjects: gg.Bool(false)})
+		if err != nil {
+			if response != nil && response.StatusCode == 404 {
+				say.Error("Group %s does not exist", group)
+				os.Exit(21)
+			}
+			say.Error("Failed retrieving response: %s", err)
+			os.Exit(21) // unknown error behaviour, fail-fast
+		}
+		say.Verbose("\nGroup %s resolved to %s", group, resolved.FullPath)
+		result = append(result, resolved.FullPath)
+	}
+	return result
+}
+
+// visibilityFilter returns the visibility for the API, nil for all projects
+func visibilityFilter(visibility string) *gg.VisibilityValue {
+	if visibility == "" {
+		return nil
+	}
+	return gg.Ptr(gg.VisibilityValue(visibility))
+}
+
+// accessLevelFilter returns the minimum access level for the API, nil for all projects
+func accessLevelFilter(minAccessLevel string) *gg.AccessLevelValue {
+	levels := map[string]gg.AccessLevelValue{
+		"guest":      gg.GuestPermissions,
+		"reporter":   gg.ReporterPermissions,
+		"developer":  gg.DeveloperPermissions,
+		"maintainer": gg.MaintainerPermissions,
+		"owner":      gg.OwnerPermissions,
+	}
+	level, exists := levels[minAccessLevel]
+	if !exists {
+		return nil
+	}
+	return gg.AccessLevel(level)
+}
+
+// activityFilter returns the earliest last activity for the API, nil for all projects
+func activityFilter(activeSince time.Duration) *time.Time {
+	if activeSince <= 0 {
+		return nil
+	}
+	return gg.Ptr(time.Now().Add(-activeSince))
+}
+
+// topicFilter returns the topics for the API, projects have to match all of the comma-separated topics
+func topicFilter(topics []string) *string {
+	if len(topics) == 0 {
+		return nil
+	}
+	return gg.Ptr(strings.Join(topics, ","))
+}
+
+// groupScopes returns the groups to list instead of all projects, if every include pattern is anchored and
+// starts with a literal group path (eg. "^platform/backend/" results in "platform/backend").
+// Without a trailing slash the last path segment might be a project prefix, therefore the parent group is used.
+func groupScopes(includePatterns []string) ([]string, bool) {
+	if len(includePatterns) == 0 {
+		return nil, false
+	}
+	var groups []string
+	for _, pattern := range includePatterns {
+		prefix := anchoredPrefix(pattern)
+		index := strings.LastIndex(prefix, "/")
+		if index <= 0 {
+			return nil, false
+		}
+		group := prefix[:index]
+		if !slices.Contains(groups, group) {
+			groups = append(groups, group)
+		}
+	}
+	return groups, true
+}
+
+// anchoredPrefix returns the literal text an anchored pattern starts with, empty if there is none
+func anchoredPrefix(pattern string) string {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil || re.Op != syntax.OpConcat || len(re.Sub) < 2 || re.Sub[0].Op != syntax.OpBeginText {
+		return ""
+	}
+	if re.Sub[1].Op != syntax.OpLiteral || re.Sub[1].Flags&syntax.FoldCase != 0 {
+		return ""
+	}
+	return string(re.Sub[1].Rune)
+}
+
+// listProjects returns all projects the user is member of
+func (g Gitlab) listProjects(options hoster.RequestOptions) []*gg.Project {
 	projectOptions := &gg.ListProjectsOptions{
 		ListOptions: gg.ListOptions{
 			PerPage: 100,
 			Page:    1,
 		},
-		Archived:   gg.Bool(false),
-		Membership: gg.Bool(true),
-		Starred:    &options.Starred,
-		//TODO include topics here to reduce query time
+		Archived:          archivedFilter(options.Archived),
+		Membership:        gg.Bool(true),
+		Starred:           &options.Starred,
+		Topic:             topicFilter(options.Topics),
+		Visibility:        visibilityFilter(options.Visibility),
+		Owned:             gg.Bool(options.Owned),
+		MinAccessLevel:    accessLevelFilter(options.MinAccessLevel),
+		LastActivityAfter: activityFilter(options.ActiveSince),
 	}
 
-	var total int
-	var repos []hoster.HosterRepository
+	var result []*gg.Project
 	var lastResponse *gg.Response
 	for ok := true; ok; ok = lastResponse.NextPage != 0 { // Loop through all pages and get list of projects
 		say.Info(".")
@@ -66,41 +236,74 @@ func (g Gitlab) Repositories(options hoster.RequestOptions) []hoster.HosterRepos
 			os.Exit(21) // unknown error behaviour, fail-fast
 		}
 		say.Verbose("\nPage: %d, Projects: %d, Statuscode: %d", projectOptions.Page, len(projectsPage), responsePage.StatusCode)
-		for _, project := range projectsPage {
-			total++
-
-			if matches(options, project.PathWithNamespace, project.TagList, project.RepositoryAccessLevel) {
-				//_, pathWithoutNamespace, _ := strings.Cut(project.PathWithNamespace, "/")
-				//say.Info("\npath: %s (was: %s)", rootlessPath, project.PathWithNamespace)
-				repos = append(repos, hoster.HosterRepository{
-					Id:                project.ID,
-					Name:              project.Name,
-					Path:              project.Path,
-					PathWithNamespace: project.PathWithNamespace,
-					//PathWithoutNamespace: pathWithoutNamespace,
-					Topics: project.TagList,
-					SshUrl: project.SSHURLToRepo,
-					WebUrl: project.WebURL})
-			}
-		}
+		result = append(result, projectsPage...)
 		projectOptions.Page++
 	}
-	say.InfoLn(" %d retrieved (%d filtered)\n", len(repos), total-len(repos))
-	return repos
+	return result
 }
 
-func matchesPattern(value string, patterns []string, expected bool, anyMatch bool) bool {
-	var result bool = len(patterns) == 0
-	for _, pattern := range patterns {
-		matched, err := regexp.MatchString(pattern, value)
-		if err != nil {
-			say.Error("Pattern matching failed unexpected for '%s' with %s", value, err)
-			os.Exit(22) // fail-fast
+// archivedFilter excludes archived projects, unless they are requested
+func archivedFilter(archived bool) *bool {
+	if archived {
+		return nil
+	}
+	return gg.Bool(false)
+}
+
+// listGroupProjects returns the projects of the groups (path or ID) and their subgroups. Without membership
+// all projects visible to the user are returned, eg. through inherited or internal visibility. With membership the
+// groups are only a scope derived from the include patterns, if one of them is not a group (eg. a user namespace),
+// all projects of the user are listed instead.
+func (g Gitlab) listGroupProjects(groups []string, membership bool, options hoster.RequestOptions) []*gg.Project {
+	var result []*gg.Project
+	ids := map[int]bool{}
+	for _, group := range groups {
+		say.Verbose("\nListing projects of group %s", group)
+		projectOptions := &gg.ListGroupProjectsOptions{
+			ListOptions: gg.ListOptions{
+				PerPage: 100,
+				Page:    1,
+			},
+			Archived:         archivedFilter(options.Archived),
+			IncludeSubGroups: gg.Bool(true),
+			Starred:          &options.Starred,
+			Topic:            topicFilter(options.Topics),
+			Visibility:       visibilityFilter(options.Visibility),
+			Owned:            gg.Bool(options.Owned),
+			MinAccessLevel:   accessLevelFilter(options.MinAccessLevel),
 		}
-		if !anyMatch && matched != expected {
-			return false
+		if membership && projectOptions.MinAccessLevel == nil {
+			projectOptions.MinAccessLevel = gg.AccessLevel(gg.GuestPermissions)
+		}
+
+		var lastResponse *gg.Response
+		for ok := true; ok; ok = lastResponse.NextPage != 0 { // Loop through all pages and get list of projects
+			say.Info(".")
+			projectsPage, responsePage, err := g.client.Groups.ListGroupProjects(group, projectOptions)
+			lastResponse = responsePage
+			if err != nil {
+				if responsePage != nil && responsePage.StatusCode == 404 {
+					if membership {
+						// the scope might be a user namespace, which is not listed by the groups API
+						say.Verbose("\nGroup %s does not exist, listing all projects of the user instead", group)
+						return g.listProjects(options)
+					}
+					say.Error("Group %s does not exist", group)
+					os.Exit(21)
+				}
+				say.Error("Failed retrieving response: %s", err)
+				os.Exit(21) // unknown error behaviour, fail-fast
+			}
+			say.Verbose("\nPage: %d, Projects: %d, Statuscode: %d", projectOptions.Page, len(projectsPage), responsePage.StatusCode)
+			for _, project := range projectsPage {
+				// overlapping groups return projects multiple times
+				if !ids[project.ID] {
+					ids[project.ID] = true
+					result = append(result, project)
+				}
+			}
+			projectOptions.Page++
 		}
-		result = result || matched == expected
 	}
 	return result
 }
@@ -110,27 +313,7 @@ func matches(options hoster.RequestOptions, path string, tags []string, projectA
 		say.Verbose("Skipping repository with disabled git repository acl")
 		return false
 	}
-	if !matchesPattern(path, options.IncludePatterns, true, true) {
-		return false
-	}
-	if !matchesPattern(path, options.ExcludePatterns, false, false) {
-		return false
-	}
-
-	for _, topic := range options.Topics {
-		var contains bool
-		for _, remote := range tags {
-			if topic == remote {
-				contains = true
-				break
-			}
-		}
-		if !contains {
-			return false
-		}
-	}
-
-	return true
+	return hoster.Matches(options, path, tags)
 }
 
 func (g Gitlab) ProjectState(projectPath string) (hoster.CleanupState, error) {
@@ -156,49 +339,98 @@ func (g Gitlab) ProjectState(projectPath string) (hoster.CleanupState, error) {
 	return hoster.Ok, nil
 }
 
-func (g Gitlab) Validate(repo model.RepoMeta, optionalManifest bool, optionalContacts bool) []error {
-	var errs []error
-	// repo.yaml itself
-	if repo.RepoYaml == nil {
-		if optionalManifest {
-			return errs
-		}
-		errs = append(errs, errors.New("No repo.yaml file exists"))
-		return errs
+// Locate resolves renamed and transferred projects by their ID. Without ID the redirect of Gitlab for the previous
+// path is used, which is lost once the path is reused.
+func (g Gitlab) Locate(projectPath string, id int) (*hoster.HosterRepository, error) {
+	say.Verbose("Locating gitlab project %s (%d)", projectPath, id)
+	var pid any = projectPath
+	if id > 0 {
+		pid = id
 	}
-	if !repo.RepoYamlValid {
-		errs = append(errs, errors.New("Invalid repo.yaml file"))
-		return errs
+	project, _, err := g.client.Projects.GetProject(pid, &gg.GetProjectOptions{})
+	if err != nil {
+		return nil, err
 	}
+	result := toRepository(project)
+	return &result, nil
+}
 
-	pattern := `^[a-z][a-z0-9-]{0,99}$`
+// projects per GraphQL request, limited by Gitlab for the fullPaths argument
+const graphqlChunkSize = 50
+
+const graphqlProjectsQuery = `query($paths: [String!]) {
+  projects(fullPaths: $paths, first: 50) {
+    nodes { fullPath archived }
+  }
+}`
+
+type graphqlProjectsResponse struct {
+	Data struct {
+		Projects struct {
+			Nodes []struct {
+				FullPath string `json:"fullPath"`
+				Archived bool   `json:"archived"`
+			} `json:"nodes"`
+		} `json:"projects"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
 
-	// name
-	if repo.Name != repo.RepoYaml.Name {
-		errs = append(errs, errors.New("names do not match ("+repo.Name+" vs. "+repo.RepoYaml.Name+")"))
+// ProjectStates determines the states with GraphQL in chunks. Projects not returned are left out, as they might be
+// removed, inaccessible or renamed (only the REST API follows renames), so they are checked with ProjectState.
+func (g Gitlab) ProjectStates(projectPaths []string) map[string]hoster.CleanupState {
+	result := map[string]hoster.CleanupState{}
+	for chunk := range slices.Chunk(projectPaths, graphqlChunkSize) {
+		say.Verbose("Retrieving %d gitlab projects with GraphQL", len(chunk))
+		response, err := g.queryProjects(chunk)
+		if err != nil {
+			say.Verbose("Unable to retrieve projects with GraphQL: %s", err)
+			continue
+		}
+		for _, project := range response.Data.Projects.Nodes {
+			state := hoster.Ok
+			if project.Archived {
+				state = hoster.Archived
+			}
+			for _, projectPath := range chunk {
+				if strings.EqualFold(projectPath, project.FullPath) {
+					result[projectPath] = state
+				}
+			}
+		}
 	}
-	// language
-	for _, lang := range repo.RepoYaml.Languages {
-		errs = *validatePattern(lang, "Language", pattern, &errs)
+	return result
+}
+
+func (g Gitlab) queryProjects(projectPaths []string) (*graphqlProjectsResponse, error) {
+	body := map[string]any{
+		"query":     graphqlProjectsQuery,
+		"variables": map[string]any{"paths": projectPaths},
 	}
-	// topics
-	for _, topic := range repo.RepoYaml.Topics {
-		errs = *validatePattern(topic, "Topic", pattern, &errs)
+	req, err := g.client.NewRequest(http.MethodPost, "", body, nil)
+	if err != nil {
+		return nil, err
+	}
+	// the GraphQL endpoint is not part of the REST API path
+	req.URL.Path, req.URL.RawPath = "/api/graphql", ""
+	req.Header.Set("Authorization", "Bearer "+g.cfg.ApiToken)
+
+	response := &graphqlProjectsResponse{}
+	if _, err := g.client.Do(req, response); err != nil {
+		return nil, err
 	}
-	// orgs
-	for orgUnit, orgName := range repo.RepoYaml.Org {
-		errs = *validatePattern(orgUnit, "Organization unit", pattern, &errs)
-		errs = *validatePattern(orgName, "Organization name", pattern, &errs)
+	if len(response.Errors) > 0 {
+		return nil, errors.New(response.Errors[0].Message)
 	}
-	// contacts
-	if len(repo.RepoYaml.Contacts) == 0 && !optionalContacts {
-		errs = append(errs, errors.New("No contacts provided"))
-	} else {
-		for _, contact := range repo.RepoYaml.Contacts {
-			if !g.contactExists(repo.RemotePath, contact) {
-				errs = append(errs, errors.New(fmt.Sprintf("User %s does not exists", contact)))
-			}
-		}
+	return response, nil
+}
+
+func (g Gitlab) Validate(repo model.RepoMeta, optionalManifest bool, optionalContacts bool) []error {
+	errs := hoster.ValidateManifest(repo, optionalManifest, optionalContacts, g.contactExists)
+	if repo.RepoYaml == nil || !repo.RepoYamlValid {
+		return errs
 	}
 	// gitlab features
 	gl := repo.RepoYaml.Gitlab
@@ -211,15 +443,6 @@ func (g Gitlab) Validate(repo model.RepoMeta, optionalManifest bool, optionalCon
 	return errs
 }
 
-func validatePattern(value, descriptiveName, pattern string, errs *[]error) *[]error {
-	matched, err := regexp.MatchString(pattern, value)
-	if err != nil || !matched {
-		newErrs := append(*errs, errors.New(fmt.Sprintf("%s '%s' does not match pattern '%s'", descriptiveName, value, pattern)))
-		return &newErrs
-	}
-	return errs
-}
-
 func (g Gitlab) contactExists(remotePath, contact string) bool {
 	opt := &gg.ListProjectUserOptions{
 		Search: &contact,
@@ -264,6 +487,9 @@ func (g Gitlab) DownloadRepoyaml(remotePath string, branch string) (*model.RepoY
 }
 
 func downloadFile(g Gitlab, remotePath string, branch string) (*gg.File, error) {
+	if branch == "" {
+		branch = "HEAD" // default branch
+	}
 	gfo := &gg.GetFileOptions{
 		Ref: gg.String(branch),
 	}
@@ -272,9 +498,9 @@ func downloadFile(g Gitlab, remotePath string, branch string) (*gg.File, error)
 	var response *gg.Response
 	var err error
 
-	for attempts := 0; attempts < config.Values.Gitlab.DownloadRetryCount; attempts++ {
+	for attempts := 0; attempts < g.cfg.DownloadRetryCount; attempts++ {
 		file, response, err = g.client.RepositoryFiles.GetFile(remotePath, model.RepoYamlFilename, gfo)
-		if err == nil {
+		if err == nil || (response != nil && response.StatusCode == 404) {
 			break
 		}
 		// retry mostly because of unreliable gitlab api due to "net/http: TLS handshake timeout"
@@ -283,7 +509,7 @@ func downloadFile(g Gitlab, remotePath string, branch string) (*gg.File, error)
 	}
 
 	if response != nil && response.StatusCode == 404 {
-		return nil, errors.New("repo.yaml does not exist")
+		return nil, hoster.ErrManifestMissing
 	}
 	if err != nil {
 		return nil, err
@@ -303,20 +529,7 @@ func (g Gitlab) Apply(repo model.RepoMeta) error {
 	say.InfoLn("Apply %s", repo.Name)
 
 	// topics
-	var topics []string
-	for _, topic := range repo.RepoYaml.Topics {
-		topics = append(topics, topic)
-	}
-	for _, lang := range repo.RepoYaml.Languages {
-		topics = append(topics, "lang_"+lang)
-	}
-	if repo.RepoYaml.Type != "" {
-		topics = append(topics, "type_"+repo.RepoYaml.Type)
-	}
-	for k, v := range repo.RepoYaml.Org {
-		topics = append(topics, "org_"+k+"_"+v)
-
-	}
+	topics := hoster.ManifestTopics(repo.RepoYaml)
 
 	var desc *string
 	if repo.RepoYaml.Description != nil {
@@ -348,6 +561,11 @@ func (g Gitlab) Apply(repo model.RepoMeta) error {
 		epo.ForkingAccessLevel = &fal
 	}
 
+	if config.Values.Options.DryRun {
+		hoster.PrintPlannedEdit(repo.RemotePath, "EditProject", epo)
+		return nil
+	}
+
 	project, response, err := g.client.Projects.EditProject(repo.RemotePath, epo)
 	if err != nil {
 		notification.NotifyInvalidRepository(repo.RemotePath, err.Error())
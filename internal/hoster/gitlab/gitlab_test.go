@@ -1,7 +1,14 @@
 package gitlab
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"repo/internal/config"
 	"repo/internal/hoster"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/xanzy/go-gitlab"
@@ -60,6 +67,26 @@ var matchCases = []matchCase{
 		options:  hoster.RequestOptions{IncludePatterns: []string{"^my-group", "somewhat$"}, ExcludePatterns: []string{"none", "matches"}},
 		expected: true,
 	},
+	{
+		path:     "my-group/foo/bar",
+		options:  hoster.RequestOptions{Groups: []string{"my-group/foo"}},
+		expected: true,
+	},
+	{
+		path:     "my-group/foo/bar",
+		options:  hoster.RequestOptions{Groups: []string{"other", "My-Group"}},
+		expected: true,
+	},
+	{
+		path:     "my-group/foo-bar",
+		options:  hoster.RequestOptions{Groups: []string{"my-group/foo"}},
+		expected: false,
+	},
+	{
+		path:     "my-group/foo/bar",
+		options:  hoster.RequestOptions{Groups: []string{"1234"}},
+		expected: false, // group IDs are resolved to their path by the hoster before
+	},
 }
 
 func TestMatches(t *testing.T) {
@@ -70,3 +97,154 @@ func TestMatches(t *testing.T) {
 		}
 	}
 }
+
+type groupScopeCase struct {
+	patterns []string
+	groups   []string
+	scoped   bool
+}
+
+var groupScopeCases = []groupScopeCase{
+	{
+		patterns: []string{},
+		scoped:   false,
+	},
+	{
+		patterns: []string{"^platform/backend/"},
+		groups:   []string{"platform/backend"},
+		scoped:   true,
+	},
+	{
+		patterns: []string{"^platform/backend"},
+		groups:   []string{"platform"},
+		scoped:   true,
+	},
+	{
+		patterns: []string{"^platform/backend/", "^platform/frontend/.*-ui$", "^platform/tools"},
+		groups:   []string{"platform/backend", "platform/frontend", "platform"},
+		scoped:   true,
+	},
+	{
+		patterns: []string{"^platform"},
+		scoped:   false,
+	},
+	{
+		patterns: []string{"platform/backend/"},
+		scoped:   false,
+	},
+	{
+		patterns: []string{"^platform/backend/", "service"},
+		scoped:   false,
+	},
+	{
+		patterns: []string{"^(platform|infra)/"},
+		scoped:   false,
+	},
+}
+
+func TestGroupScopes(t *testing.T) {
+	for _, test := range groupScopeCases {
+		groups, scoped := groupScopes(test.patterns)
+		if scoped != test.scoped || !slices.Equal(groups, test.groups) {
+			t.Errorf("got %v %t, wanted %v %t for %v", groups, scoped, test.groups, test.scoped, test.patterns)
+		}
+	}
+}
+
+func TestResolveGroups(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v4/groups/1234" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": 1234, "full_path": "platform/backend"}`))
+	}))
+	defer server.Close()
+
+	client, err := gitlab.NewClient("token", gitlab.WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := Gitlab{client: client}
+
+	groups := g.resolveGroups([]string{"1234", "other"})
+	if !slices.Equal(groups, []string{"platform/backend", "other"}) {
+		t.Errorf("got %v, wanted [platform/backend other]", groups)
+	}
+}
+
+func TestProjectStates(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/graphql" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		requests++
+		body := struct {
+			Variables struct {
+				Paths []string `json:"paths"`
+			} `json:"variables"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&body)
+		var nodes []string
+		for _, path := range body.Variables.Paths {
+			switch path {
+			case "group/archived":
+				nodes = append(nodes, `{"fullPath": "group/archived", "archived": true}`)
+			case "group/removed":
+			default:
+				nodes = append(nodes, `{"fullPath": "`+strings.ToLower(path)+`", "archived": false}`)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data": {"projects": {"nodes": [` + strings.Join(nodes, ",") + `]}}}`))
+	}))
+	defer server.Close()
+
+	client, err := gitlab.NewClient("token", gitlab.WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := Gitlab{client: client}
+
+	paths := []string{"group/archived", "group/removed", "Group/Mixed-Case"}
+	for i := 0; i < graphqlChunkSize; i++ {
+		paths = append(paths, "group/project-"+strconv.Itoa(i))
+	}
+	states := g.ProjectStates(paths)
+
+	if requests != 2 {
+		t.Errorf("got %d requests, wanted 2", requests)
+	}
+	if len(states) != len(paths)-1 {
+		t.Errorf("got %d states, wanted %d", len(states), len(paths)-1)
+	}
+	if states["group/archived"] != hoster.Archived || states["Group/Mixed-Case"] != hoster.Ok {
+		t.Errorf("unexpected states %v", states)
+	}
+	if _, exists := states["group/removed"]; exists {
+		t.Errorf("unexpected state for missing project")
+	}
+}
+
+func TestAccessLevelFilter(t *testing.T) {
+	if level := accessLevelFilter("developer"); level == nil || *level != gitlab.DeveloperPermissions {
+		t.Errorf("Expected developer permissions, got %v", level)
+	}
+	if level := accessLevelFilter(""); level != nil {
+		t.Errorf("Expected no filter, got %v", *level)
+	}
+}
+
+func TestMatchesSecurityToken(t *testing.T) {
+	t.Setenv(GITLAB_SECRET_TOKEN, "from-env")
+	request := httptest.NewRequest(http.MethodPost, "/webhook/work", nil)
+	request.Header.Set("X-Gitlab-Token", "from-instance")
+
+	if !matchesSecurityToken(request, config.Hoster{SecretToken: "from-instance"}) {
+		t.Error("expected the secret of the instance to match")
+	}
+	if matchesSecurityToken(request, config.Hoster{}) {
+		t.Error("expected the environment to be used without secret of the instance")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"io/ioutil"
 	"net/http"
+	"repo/internal/config"
 	"repo/internal/hoster"
 	"repo/internal/util"
 
@@ -22,8 +23,8 @@ type WebHookPush struct {
 const REPOW_GITLAB_SECRET_TOKEN = "REPOW_GITLAB_SECRET_TOKEN"
 const GITLAB_SECRET_TOKEN = "GITLAB_SECRET_TOKEN"
 
-func HandleWebhookGitlab(w http.ResponseWriter, r *http.Request) (hoster.Hoster, *WebHookPush, error) {
-	if !matchesSecurityToken(r) {
+func HandleWebhookGitlab(w http.ResponseWriter, r *http.Request, cfg config.Hoster) (hoster.Hoster, *WebHookPush, error) {
+	if !matchesSecurityToken(r, cfg) {
 		return nil, nil, errors.New("security-token does not match")
 	}
 
@@ -33,7 +34,7 @@ func HandleWebhookGitlab(w http.ResponseWriter, r *http.Request) (hoster.Hoster,
 		return nil, nil, nil
 	}
 
-	hoster, err := MakeHoster()
+	hoster, err := MakeHoster(cfg)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -51,9 +52,12 @@ func HandleWebhookGitlab(w http.ResponseWriter, r *http.Request) (hoster.Hoster,
 
 }
 
-func matchesSecurityToken(r *http.Request) bool {
+func matchesSecurityToken(r *http.Request, cfg config.Hoster) bool {
 	// docs: https://docs.gitlab.com/ce/user/project/integrations/webhooks.html#secret-token
 	secretToken := r.Header.Get("X-Gitlab-Token") // if configured
-	value := util.GetEnv(REPOW_GITLAB_SECRET_TOKEN, util.GetEnv(GITLAB_SECRET_TOKEN, ""))
+	value := cfg.SecretToken
+	if value == "" { // the environment is only the fallback, it would apply to every instance
+		value = util.GetEnv(REPOW_GITLAB_SECRET_TOKEN, util.GetEnv(GITLAB_SECRET_TOKEN, ""))
+	}
 	return value == secretToken
 }
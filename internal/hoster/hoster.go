@@ -1,26 +1,69 @@
 package hoster
 
 import (
+	"errors"
 	"fmt"
 	"repo/internal/model"
+	"time"
 )
 
+// ErrManifestMissing is returned by DownloadRepoyaml, if the repository has no repo.yaml
+var ErrManifestMissing = errors.New("repo.yaml does not exist")
+
 type RequestOptions struct {
 	Topics          []string
 	Starred         bool
 	ExcludePatterns []string
 	IncludePatterns []string
+	Groups          []string
+	Archived        bool
+	Visibility      string        // "private", "internal" or "public", empty for all
+	NoForks         bool          // excludes forked repositories
+	Owned           bool          // only repositories owned by the user
+	MinAccessLevel  string        // minimum access level of the user, eg. "developer", empty for all
+	ActiveSince     time.Duration // last activity within the duration, 0 for all
 }
 
+// Visibilities are the values for the visibility filter
+var Visibilities = []string{"private", "internal", "public"}
+
+// AccessLevels are the values for the minimum access level filter, in ascending order
+var AccessLevels = []string{"guest", "reporter", "developer", "maintainer", "owner"}
+
 type Hoster interface {
 	Repositories(options RequestOptions) []HosterRepository
 	ProjectState(projectPath string) (CleanupState, error)
+	Name() string
 	Host() string
+	SshAccess() (user string, port int)
 	Validate(repo model.RepoMeta, optionalManifest bool, optionalContacts bool) []error
 	DownloadRepoyaml(remotePath string, ref string) (*model.RepoYaml, bool, error)
 	Apply(repo model.RepoMeta) error
 }
 
+// BulkHoster is implemented by hosters, which are able to determine the state of many repositories at once.
+// Repositories without a determined state are missing in the result, their state has to be requested separately.
+type BulkHoster interface {
+	ProjectStates(projectPaths []string) map[string]CleanupState
+}
+
+// LocatingHoster is implemented by hosters, which follow renamed and transferred repositories.
+// Locate returns the repository with its current path by its ID, or for a previous path if the ID is unknown (0).
+type LocatingHoster interface {
+	Locate(projectPath string, id int) (*HosterRepository, error)
+}
+
+// PartialTopicsHoster is implemented by hosters, which only know the topics of the repositories for the requested
+// topics (eg. Bitbucket labels). Their listing can't be matched against other topics afterwards.
+type PartialTopicsHoster interface {
+	PartialTopics()
+}
+
+// VisibilityHoster is implemented by hosters, which only know some of the Visibilities
+type VisibilityHoster interface {
+	Visibilities() []string
+}
+
 type HosterRepository struct {
 	Id                   int
 	Name                 string
@@ -29,7 +72,13 @@ type HosterRepository struct {
 	PathWithoutNamespace string
 	Topics               []string
 	SshUrl               string
+	HttpUrl              string
 	WebUrl               string
+	Archived             bool
+	Visibility           string
+	Fork                 bool
+	LastActivity         time.Time
+	DefaultBranch        string
 }
 
 type CleanupState int
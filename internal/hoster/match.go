@@ -0,0 +1,173 @@
+package hoster
+
+import (
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"repo/internal/model"
+	"repo/internal/say"
+)
+
+func matchesPattern(value string, patterns []string, expected bool, anyMatch bool) bool {
+	var result bool = len(patterns) == 0
+	for _, pattern := range patterns {
+		matched, err := regexp.MatchString(pattern, value)
+		if err != nil {
+			say.Error("Pattern matching failed unexpected for '%s' with %s", value, err)
+			os.Exit(22) // fail-fast
+		}
+		if !anyMatch && matched != expected {
+			return false
+		}
+		result = result || matched == expected
+	}
+	return result
+}
+
+// checks if the path is part of one of the groups (or subgroups), the groups are given by their path
+func inGroups(path string, groups []string) bool {
+	if len(groups) == 0 {
+		return true
+	}
+	for _, group := range groups {
+		prefix := strings.Trim(group, "/") + "/"
+		if len(path) > len(prefix) && strings.EqualFold(path[:len(prefix)], prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsGroupId checks if the group is given by its ID instead of its path
+func IsGroupId(group string) bool {
+	_, err := strconv.Atoi(group)
+	return err == nil
+}
+
+// WithoutGroupIds removes the groups given by their ID from the options with a warning, for hosters that can't resolve them
+func WithoutGroupIds(hosterName string, options RequestOptions) RequestOptions {
+	var groups []string
+	for _, group := range options.Groups {
+		if IsGroupId(group) {
+			say.Warn("\nGroup IDs are not supported by %s, ignoring group %s", hosterName, group)
+			continue
+		}
+		groups = append(groups, group)
+	}
+	options.Groups = groups
+	return options
+}
+
+// Matches checks the path and topics of a repository against the include/exclude patterns and topics of the options
+func Matches(options RequestOptions, path string, topics []string) bool {
+	if !matchesPattern(path, options.IncludePatterns, true, true) {
+		return false
+	}
+	if !matchesPattern(path, options.ExcludePatterns, false, false) {
+		return false
+	}
+
+	if !inGroups(path, options.Groups) {
+		return false
+	}
+
+	for _, topic := range options.Topics {
+		if !slices.Contains(topics, topic) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MatchesAttributes checks the visibility, fork status and last activity of a repository. Values not provided by the hoster are not filtered.
+func MatchesAttributes(options RequestOptions, repo HosterRepository) bool {
+	if options.Visibility != "" && repo.Visibility != "" && !strings.EqualFold(options.Visibility, repo.Visibility) {
+		return false
+	}
+	if options.NoForks && repo.Fork {
+		return false
+	}
+	if options.ActiveSince > 0 && !repo.LastActivity.IsZero() && repo.LastActivity.Before(time.Now().Add(-options.ActiveSince)) {
+		return false
+	}
+	return true
+}
+
+// HasAccessLevel checks if the access level of the user is at least the minimum access level, both are one of AccessLevels
+func HasAccessLevel(minAccessLevel string, accessLevel string) bool {
+	return minAccessLevel == "" || slices.Index(AccessLevels, accessLevel) >= slices.Index(AccessLevels, minAccessLevel)
+}
+
+// WarnUnsupported warns about the filters set in the options, which are not supported by the hoster and therefore ignored
+func WarnUnsupported(hosterName string, options RequestOptions, supported ...string) {
+	filters := map[string]bool{
+		"visibility":       options.Visibility != "",
+		"no-forks":         options.NoForks,
+		"owned":            options.Owned,
+		"min-access-level": options.MinAccessLevel != "",
+		"active-since":     options.ActiveSince > 0,
+	}
+	for _, filter := range []string{"visibility", "no-forks", "owned", "min-access-level", "active-since"} {
+		if filters[filter] && !slices.Contains(supported, filter) {
+			say.Warn("\n--%s is not supported by %s, ignoring filter", filter, hosterName)
+		}
+	}
+}
+
+// WithKnownVisibility removes a visibility filter the hoster doesn't know with a warning, otherwise no repository would match
+func WithKnownVisibility(h Hoster, options RequestOptions) RequestOptions {
+	known, ok := h.(VisibilityHoster)
+	if !ok || options.Visibility == "" || slices.Contains(known.Visibilities(), options.Visibility) {
+		return options
+	}
+	say.Warn("\n--visibility %s is not supported by %s, ignoring filter", options.Visibility, h.Name())
+	options.Visibility = ""
+	return options
+}
+
+// ManifestOptions filter repositories by the values of their repo.yaml
+type ManifestOptions struct {
+	Type        string
+	Org         map[string][]string // all keys have to match one of their values
+	Annotations map[string][]string // all keys have to match one of their values
+	Contacts    []string            // one of the contacts has to be listed
+}
+
+// IsEmpty is true, if no filter is set and the repo.yaml is not required
+func (o ManifestOptions) IsEmpty() bool {
+	return o.Type == "" && len(o.Org) == 0 && len(o.Annotations) == 0 && len(o.Contacts) == 0
+}
+
+// MatchesManifest checks the repo.yaml against the options, repositories without repo.yaml only match empty options
+func MatchesManifest(options ManifestOptions, repoYaml *model.RepoYaml) bool {
+	if options.IsEmpty() {
+		return true
+	}
+	if repoYaml == nil {
+		return false
+	}
+	if options.Type != "" && !strings.EqualFold(options.Type, repoYaml.Type) {
+		return false
+	}
+	for key, values := range options.Org {
+		if !slices.Contains(values, repoYaml.Org[key]) {
+			return false
+		}
+	}
+	for key, values := range options.Annotations {
+		if !slices.Contains(values, repoYaml.Annotations[key]) {
+			return false
+		}
+	}
+	if len(options.Contacts) > 0 && !slices.ContainsFunc(options.Contacts, func(contact string) bool {
+		return slices.Contains(repoYaml.Contacts, contact)
+	}) {
+		return false
+	}
+	return true
+}
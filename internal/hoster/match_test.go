@@ -0,0 +1,100 @@
+package hoster
+
+import (
+	"repo/internal/model"
+	"testing"
+	"time"
+)
+
+type attributeCase struct {
+	repo     HosterRepository
+	options  RequestOptions
+	expected bool
+}
+
+var lastWeek = time.Now().Add(-7 * 24 * time.Hour)
+
+var attributeCases = []attributeCase{
+	{
+		repo:     HosterRepository{Visibility: "private"},
+		options:  RequestOptions{Visibility: "private"},
+		expected: true,
+	},
+	{
+		repo:     HosterRepository{Visibility: "internal"},
+		options:  RequestOptions{Visibility: "private"},
+		expected: false,
+	},
+	{
+		repo:     HosterRepository{Fork: true},
+		options:  RequestOptions{NoForks: true},
+		expected: false,
+	},
+	{
+		repo:     HosterRepository{Fork: true},
+		options:  RequestOptions{},
+		expected: true,
+	},
+	{
+		repo:     HosterRepository{LastActivity: lastWeek},
+		options:  RequestOptions{ActiveSince: 30 * 24 * time.Hour},
+		expected: true,
+	},
+	{
+		repo:     HosterRepository{LastActivity: lastWeek},
+		options:  RequestOptions{ActiveSince: 24 * time.Hour},
+		expected: false,
+	},
+	{
+		repo:     HosterRepository{},
+		options:  RequestOptions{Visibility: "public", ActiveSince: 24 * time.Hour},
+		expected: true,
+	},
+}
+
+func TestMatchesAttributes(t *testing.T) {
+	for _, test := range attributeCases {
+		got := MatchesAttributes(test.options, test.repo)
+		if got != test.expected {
+			t.Errorf("got %t, wanted %t for %+v", got, test.expected, test.options)
+		}
+	}
+}
+
+var service = &model.RepoYaml{
+	Type:        "service",
+	Org:         map[string]string{"squad": "user", "department": "platform"},
+	Annotations: map[string]string{"acme.corp/access": "iam"},
+	Contacts:    []string{"galan", "someone"},
+}
+
+type manifestCase struct {
+	repoYaml *model.RepoYaml
+	options  ManifestOptions
+	expected bool
+}
+
+var manifestCases = []manifestCase{
+	{repoYaml: nil, options: ManifestOptions{}, expected: true},
+	{repoYaml: nil, options: ManifestOptions{Type: "service"}, expected: false},
+	{repoYaml: service, options: ManifestOptions{Type: "service"}, expected: true},
+	{repoYaml: service, options: ManifestOptions{Type: "library"}, expected: false},
+	{repoYaml: service, options: ManifestOptions{Org: map[string][]string{"squad": {"user"}}}, expected: true},
+	{repoYaml: service, options: ManifestOptions{Org: map[string][]string{"squad": {"billing", "user"}}}, expected: true},
+	{repoYaml: service, options: ManifestOptions{Org: map[string][]string{"squad": {"user"}, "department": {"sales"}}}, expected: false},
+	{repoYaml: service, options: ManifestOptions{Org: map[string][]string{"tribe": {"user"}}}, expected: false},
+	{repoYaml: service, options: ManifestOptions{Annotations: map[string][]string{"acme.corp/access": {"iam"}}}, expected: true},
+	{repoYaml: service, options: ManifestOptions{Annotations: map[string][]string{"acme.corp/access": {"public"}}}, expected: false},
+	{repoYaml: service, options: ManifestOptions{Contacts: []string{"nobody", "galan"}}, expected: true},
+	{repoYaml: service, options: ManifestOptions{Contacts: []string{"nobody"}}, expected: false},
+	{repoYaml: service, options: ManifestOptions{Type: "service", Contacts: []string{"galan"}, Org: map[string][]string{"squad": {"user"}}}, expected: true},
+}
+
+func TestMatchesManifest(t *testing.T) {
+	for _, test := range manifestCases {
+		got := MatchesManifest(test.options, test.repoYaml)
+		if got != test.expected {
+			t.Errorf("got %t, wanted %t for %+v", got, test.expected, test.options)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package plain
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"repo/internal/config"
+	"repo/internal/gitclient"
+	"repo/internal/hoster"
+	"repo/internal/model"
+	"repo/internal/say"
+
+	"gopkg.in/yaml.v2"
+)
+
+const HosterType string = "plain"
+
+func init() {
+	hoster.Register(HosterType, func(cfg config.Hoster) (hoster.Hoster, error) {
+		return MakeHoster(cfg)
+	})
+}
+
+// Entry is a single repository in the repository list file
+type Entry struct {
+	Url    string   `yaml:"url"`
+	Path   string   `yaml:"path"`
+	Topics []string `yaml:"topics"`
+}
+
+// MakeHoster creates a hoster without API, the repositories are read from a static YAML/JSON list file
+func MakeHoster(cfg config.Hoster) (*Plain, error) {
+	result := &Plain{cfg: cfg}
+	if cfg.Host == "" {
+		return result, fmt.Errorf("the host has to be set (hoster %s)", cfg.Name)
+	}
+	if cfg.File == "" {
+		return result, fmt.Errorf("the repository list file has to be set (hoster %s)", cfg.Name)
+	}
+
+	content, err := os.ReadFile(cfg.File)
+	if err != nil {
+		return nil, err
+	}
+	result.entries, err = parseEntries(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository list file %s: %s", cfg.File, err)
+	}
+	return result, nil
+}
+
+type Plain struct {
+	cfg     config.Hoster
+	entries []Entry
+}
+
+// parses the list file content, JSON is read as YAML subset
+func parseEntries(content []byte) ([]Entry, error) {
+	var entries []Entry
+	if err := yaml.Unmarshal(content, &entries); err != nil {
+		return nil, err
+	}
+	for i, entry := range entries {
+		if entry.Url == "" {
+			return nil, fmt.Errorf("missing url for entry %d", i+1)
+		}
+		if entry.Path == "" {
+			entries[i].Path = pathFromUrl(entry.Url)
+		}
+	}
+	return entries, nil
+}
+
+// derives the path from the url, eg. "git@host:group/repo.git" results in "group/repo"
+func pathFromUrl(url string) string {
+	result := url
+	if _, after, found := strings.Cut(result, "://"); found {
+		_, result, _ = strings.Cut(after, "/")
+	} else if _, after, found := strings.Cut(result, ":"); found {
+		result = after
+	}
+	return strings.TrimSuffix(strings.Trim(result, "/"), ".git")
+}
+
+// the url is only usable for the https transport, if it is a http(s) url
+func httpUrl(url string) string {
+	if strings.HasPrefix(url, "https://") || strings.HasPrefix(url, "http://") {
+		return url
+	}
+	return ""
+}
+
+func (p Plain) Host() string {
+	return p.cfg.Host
+}
+
+func (p Plain) Name() string {
+	return p.cfg.Name
+}
+
+func (p Plain) SshAccess() (string, int) {
+	return p.cfg.SSHUser, p.cfg.SSHPort
+}
+
+func (p Plain) Repositories(options hoster.RequestOptions) []hoster.HosterRepository {
+	say.Info("Reading repository list %s", p.cfg.File)
+	options = hoster.WithoutGroupIds("plain git hosters", options)
+	if options.Starred {
+		say.Warn("\nPlain git hosters do not support starred repositories, ignoring filter")
+	}
+	hoster.WarnUnsupported("plain git hosters", options)
+	var repos []hoster.HosterRepository
+	for _, entry := range p.entries {
+		if hoster.Matches(options, entry.Path, entry.Topics) {
+			name := entry.Path[strings.LastIndex(entry.Path, "/")+1:]
+			repos = append(repos, hoster.HosterRepository{
+				Name:              name,
+				Path:              name,
+				PathWithNamespace: entry.Path,
+				Topics:            entry.Topics,
+				SshUrl:            entry.Url,
+				HttpUrl:           httpUrl(entry.Url)})
+		}
+	}
+	say.InfoLn(" %d retrieved (%d filtered)\n", len(repos), len(p.entries)-len(repos))
+	return repos
+}
+
+// the entry for the path, either the given path or the one of the url
+func (p Plain) entry(projectPath string) (Entry, bool) {
+	for _, entry := range p.entries {
+		if entry.Path == projectPath || pathFromUrl(entry.Url) == projectPath {
+			return entry, true
+		}
+	}
+	return Entry{}, false
+}
+
+// ProjectState checks the remote with git ls-remote, as there is no API. Archived repositories can not be detected.
+func (p Plain) ProjectState(projectPath string) (hoster.CleanupState, error) {
+	entry, exists := p.entry(projectPath)
+	if !exists {
+		return hoster.Unknown, errors.New("repository is not listed in " + p.cfg.File)
+	}
+	say.Verbose("Checking remote %s", entry.Url)
+	ok, stderr := gitclient.LsRemote(entry.Url)
+	if ok {
+		return hoster.Ok, nil
+	}
+	if strings.Contains(strings.ToLower(stderr), "not found") {
+		return hoster.Removed, nil
+	}
+	return hoster.Unknown, errors.New(stderr)
+}
+
+// Validate checks the manifest, contacts can not be verified without API
+func (p Plain) Validate(repo model.RepoMeta, optionalManifest bool, optionalContacts bool) []error {
+	return hoster.ValidateManifest(repo, optionalManifest, optionalContacts, func(remotePath, contact string) bool {
+		return true
+	})
+}
+
+func (p Plain) DownloadRepoyaml(remotePath string, ref string) (*model.RepoYaml, bool, error) {
+	return nil, false, errors.New("downloading the repo.yaml is not supported for plain git hosters")
+}
+
+func (p Plain) Apply(repo model.RepoMeta) error {
+	say.Error("Applying the manifest is not supported for plain git hosters (%s)", repo.RemotePath)
+	return errors.New("apply is not supported for plain git hosters")
+}
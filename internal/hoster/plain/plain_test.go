@@ -0,0 +1,48 @@
+package plain
+
+import (
+	"testing"
+)
+
+type pathCase struct {
+	url      string
+	expected string
+}
+
+var pathCases = []pathCase{
+	{
+		url:      "git@git.acme.corp:infra/legacy-tool.git",
+		expected: "infra/legacy-tool",
+	},
+	{
+		url:      "ssh://git@git.acme.corp:2222/infra/legacy-tool.git",
+		expected: "infra/legacy-tool",
+	},
+	{
+		url:      "https://git.acme.corp/infra/legacy-tool",
+		expected: "infra/legacy-tool",
+	},
+}
+
+func TestPathFromUrl(t *testing.T) {
+	for _, test := range pathCases {
+		got := pathFromUrl(test.url)
+		if got != test.expected {
+			t.Errorf("got %s, wanted %s", got, test.expected)
+		}
+	}
+}
+
+func TestParseEntries(t *testing.T) {
+	content := `[{"url": "git@git.acme.corp:infra/legacy-tool.git", "topics": ["legacy"]}, {"url": "git@git.acme.corp:x.git", "path": "other/x"}]`
+	entries, err := parseEntries([]byte(content))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(entries) != 2 || entries[0].Path != "infra/legacy-tool" || entries[1].Path != "other/x" || entries[0].Topics[0] != "legacy" {
+		t.Errorf("unexpected entries %v", entries)
+	}
+	if _, err := parseEntries([]byte(`[{"path": "a/b"}]`)); err == nil {
+		t.Errorf("expected error for missing url")
+	}
+}
@@ -0,0 +1,102 @@
+package hoster
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"repo/internal/config"
+	"repo/internal/say"
+)
+
+// Factory creates a hoster for the given hoster instance configuration
+type Factory func(cfg config.Hoster) (Hoster, error)
+
+var factories = map[string]Factory{}
+
+// Register makes a hoster type available, called by the hoster implementations on init
+func Register(hosterType string, factory Factory) {
+	if _, exists := factories[hosterType]; exists {
+		panic("hoster already registered: " + hosterType)
+	}
+	factories[hosterType] = factory
+}
+
+// Types returns the sorted types of all registered hosters
+func Types() []string {
+	var result []string
+	for hosterType := range factories {
+		result = append(result, hosterType)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// MakeHoster creates the hoster for the given hoster instance configuration
+func MakeHoster(cfg config.Hoster) (Hoster, error) {
+	factory, exists := factories[cfg.Type]
+	if !exists {
+		return nil, fmt.Errorf("invalid type for hoster %q: %q (available: %s)", cfg.Name, cfg.Type, Types())
+	}
+	return factory(cfg)
+}
+
+// MakeHosterNamed creates the configured hoster instance with the given name
+func MakeHosterNamed(name string) (Hoster, error) {
+	if err := config.ValidateHosters(); err != nil {
+		return nil, err
+	}
+	cfg, exists := config.HosterEntry(name)
+	if !exists {
+		var names []string
+		for _, entry := range config.HosterEntries() {
+			names = append(names, entry.Name)
+		}
+		return nil, fmt.Errorf("invalid value for hoster: %q (configured: %s)", name, names)
+	}
+	return MakeHoster(cfg)
+}
+
+// MakeHosters creates all configured hoster instances, instances that can not be created (eg. missing API-token) are skipped
+func MakeHosters() (Hosters, error) {
+	if err := config.ValidateHosters(); err != nil {
+		return nil, err
+	}
+	var result Hosters
+	var errs []error
+	for _, cfg := range config.HosterEntries() {
+		hoster, err := MakeHoster(cfg)
+		if err != nil {
+			say.Verbose("Skipping hoster %s: %s", cfg.Name, err)
+			errs = append(errs, err)
+			continue
+		}
+		result = append(result, hoster)
+	}
+	if len(result) == 0 {
+		return nil, errors.Join(errs...)
+	}
+	return result, nil
+}
+
+// Hosters is a list of hoster instances
+type Hosters []Hoster
+
+// Hosts returns the hosts of all hoster instances
+func (hs Hosters) Hosts() []string {
+	var result []string
+	for _, hoster := range hs {
+		result = append(result, hoster.Host())
+	}
+	return result
+}
+
+// ByHost returns the first hoster instance for the given host, nil if none exists
+func (hs Hosters) ByHost(host string) Hoster {
+	for _, hoster := range hs {
+		if hoster.Host() == host {
+			return hoster
+		}
+	}
+	return nil
+}
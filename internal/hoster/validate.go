@@ -0,0 +1,96 @@
+package hoster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"repo/internal/model"
+	"repo/internal/say"
+)
+
+// ValidateManifest validates the hoster independent parts of the repo.yaml, the existence of contacts is checked with the passed function
+func ValidateManifest(repo model.RepoMeta, optionalManifest bool, optionalContacts bool, contactExists func(remotePath, contact string) bool) []error {
+	var errs []error
+	// repo.yaml itself
+	if repo.RepoYaml == nil {
+		if optionalManifest {
+			return errs
+		}
+		errs = append(errs, errors.New("No repo.yaml file exists"))
+		return errs
+	}
+	if !repo.RepoYamlValid {
+		errs = append(errs, errors.New("Invalid repo.yaml file"))
+		return errs
+	}
+
+	pattern := `^[a-z][a-z0-9-]{0,99}$`
+
+	// name
+	if repo.Name != repo.RepoYaml.Name {
+		errs = append(errs, errors.New("names do not match ("+repo.Name+" vs. "+repo.RepoYaml.Name+")"))
+	}
+	// language
+	for _, lang := range repo.RepoYaml.Languages {
+		errs = *validatePattern(lang, "Language", pattern, &errs)
+	}
+	// topics
+	for _, topic := range repo.RepoYaml.Topics {
+		errs = *validatePattern(topic, "Topic", pattern, &errs)
+	}
+	// orgs
+	for orgUnit, orgName := range repo.RepoYaml.Org {
+		errs = *validatePattern(orgUnit, "Organization unit", pattern, &errs)
+		errs = *validatePattern(orgName, "Organization name", pattern, &errs)
+	}
+	// contacts
+	if len(repo.RepoYaml.Contacts) == 0 && !optionalContacts {
+		errs = append(errs, errors.New("No contacts provided"))
+	} else {
+		for _, contact := range repo.RepoYaml.Contacts {
+			if !contactExists(repo.RemotePath, contact) {
+				errs = append(errs, errors.New(fmt.Sprintf("User %s does not exists", contact)))
+			}
+		}
+	}
+	return errs
+}
+
+func validatePattern(value, descriptiveName, pattern string, errs *[]error) *[]error {
+	matched, err := regexp.MatchString(pattern, value)
+	if err != nil || !matched {
+		newErrs := append(*errs, errors.New(fmt.Sprintf("%s '%s' does not match pattern '%s'", descriptiveName, value, pattern)))
+		return &newErrs
+	}
+	return errs
+}
+
+// ManifestTopics flattens the repo.yaml values into the list of topics applied to the hoster
+func ManifestTopics(repoYaml *model.RepoYaml) []string {
+	var topics []string
+	for _, topic := range repoYaml.Topics {
+		topics = append(topics, topic)
+	}
+	for _, lang := range repoYaml.Languages {
+		topics = append(topics, "lang_"+lang)
+	}
+	if repoYaml.Type != "" {
+		topics = append(topics, "type_"+repoYaml.Type)
+	}
+	for k, v := range repoYaml.Org {
+		topics = append(topics, "org_"+k+"_"+v)
+	}
+	return topics
+}
+
+// PrintPlannedEdit outputs the request apply would send to the hoster, used instead of the request for dry-runs
+func PrintPlannedEdit(remotePath string, request string, payload any) {
+	content, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		say.Error("Unable to print planned %s for %s: %s", request, remotePath, err)
+		return
+	}
+	say.InfoLn("Planned %s for %s [dry-run]:\n%s", request, remotePath, content)
+}
@@ -0,0 +1,90 @@
+package layout
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Data contains the values of a repository available in a path template
+type Data struct {
+	Host              string   // host of the hoster, eg. "gitlab.com"
+	Namespace         string   // groups of the repository, eg. "acme/platform"
+	Path              string   // path of the repository without namespace, eg. "service"
+	PathWithNamespace string   // complete path, eg. "acme/platform/service"
+	Name              string   // display name of the repository
+	Topics            []string // topics of the repository
+}
+
+// MakeData creates the template data for a repository path
+func MakeData(host string, pathWithNamespace string, repoPath string, name string, topics []string) Data {
+	namespace, _ := path.Split(pathWithNamespace)
+	return Data{
+		Host:              host,
+		Namespace:         strings.TrimSuffix(namespace, "/"),
+		Path:              repoPath,
+		PathWithNamespace: pathWithNamespace,
+		Name:              name,
+		Topics:            topics,
+	}
+}
+
+var funcs = template.FuncMap{
+	// arguments are swapped compared to the strings package, to be used in pipelines like `.Namespace | trimPrefix "acme/"`
+	"trimPrefix": func(prefix string, value string) string { return strings.TrimPrefix(value, prefix) },
+	"trimSuffix": func(suffix string, value string) string { return strings.TrimSuffix(value, suffix) },
+	"replace":    func(old string, new string, value string) string { return strings.ReplaceAll(value, old, new) },
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+}
+
+// Template resolves the relative target directory of a repository
+type Template struct {
+	text     string
+	template *template.Template
+}
+
+// Parse creates a path template, eg. `{{.Namespace | trimPrefix "acme/"}}/{{.Path}}` or `{{index .Topics 0}}/{{.Path}}`
+func Parse(text string) (*Template, error) {
+	t, err := template.New("path").Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path template %q: %s", text, err)
+	}
+	return &Template{text: text, template: t}, nil
+}
+
+// Execute resolves the relative target directory, which has to stay within the root directory
+func (t *Template) Execute(data Data) (string, error) {
+	var buffer bytes.Buffer
+	if err := t.template.Execute(&buffer, data); err != nil {
+		return "", err
+	}
+	result := path.Clean(strings.TrimSpace(buffer.String()))
+	if result == "." || result == "" {
+		return "", errors.New("path template results in an empty path")
+	}
+	if path.IsAbs(result) || result == ".." || strings.HasPrefix(result, "../") {
+		return "", fmt.Errorf("path template results in a path outside of the root directory: %s", result)
+	}
+	return result, nil
+}
+
+// Collisions returns the target paths claimed by more than one repository, with the sorted repositories per target
+func Collisions(targets map[string]string) map[string][]string {
+	byTarget := map[string][]string{}
+	for repo, target := range targets {
+		byTarget[target] = append(byTarget[target], repo)
+	}
+	result := map[string][]string{}
+	for target, repos := range byTarget {
+		if len(repos) > 1 {
+			sort.Strings(repos)
+			result[target] = repos
+		}
+	}
+	return result
+}
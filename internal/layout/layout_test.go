@@ -0,0 +1,85 @@
+package layout
+
+import (
+	"slices"
+	"testing"
+)
+
+type executeCase struct {
+	template string
+	data     Data
+	expected string
+	failing  bool
+}
+
+var data = MakeData("gitlab.com", "acme/platform/service", "service", "Service", []string{"backend", "library"})
+
+var executeCases = []executeCase{
+	{
+		template: `{{.Namespace | trimPrefix "acme/"}}/{{.Path}}`,
+		data:     data,
+		expected: "platform/service",
+	},
+	{
+		template: `{{index .Topics 0}}/{{.Path}}`,
+		data:     data,
+		expected: "backend/service",
+	},
+	{
+		template: `{{.Host}}/{{.PathWithNamespace | lower}}`,
+		data:     data,
+		expected: "gitlab.com/acme/platform/service",
+	},
+	{
+		template: `{{.Namespace | replace "/" "-"}}_{{.Path}}`,
+		data:     data,
+		expected: "acme-platform_service",
+	},
+	{
+		template: `{{index .Topics 0}}/{{.Path}}`,
+		data:     MakeData("gitlab.com", "acme/other", "other", "Other", nil),
+		failing:  true,
+	},
+	{
+		template: `../{{.Path}}`,
+		data:     data,
+		failing:  true,
+	},
+	{
+		template: `/{{.Path}}`,
+		data:     data,
+		failing:  true,
+	},
+	{
+		template: `{{.Unknown}}`,
+		data:     data,
+		failing:  true,
+	},
+}
+
+func TestExecute(t *testing.T) {
+	for _, test := range executeCases {
+		tmpl, err := Parse(test.template)
+		if err != nil {
+			t.Fatalf("unexpected parse error for %s: %s", test.template, err)
+		}
+		got, err := tmpl.Execute(test.data)
+		if test.failing != (err != nil) {
+			t.Errorf("got error %v for %s, wanted failing %t", err, test.template, test.failing)
+		}
+		if got != test.expected {
+			t.Errorf("got %s, wanted %s for %s", got, test.expected, test.template)
+		}
+	}
+}
+
+func TestCollisions(t *testing.T) {
+	collisions := Collisions(map[string]string{
+		"a/service": "service",
+		"b/service": "service",
+		"a/other":   "other",
+	})
+	if len(collisions) != 1 || !slices.Equal(collisions["service"], []string{"a/service", "b/service"}) {
+		t.Errorf("unexpected collisions %v", collisions)
+	}
+}
@@ -0,0 +1,64 @@
+package lockfile
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Version of the lockfile format, increased on incompatible changes
+const Version int = 1
+
+// Lockfile is the snapshot of the repositories below a root directory
+type Lockfile struct {
+	Version      int     `yaml:"version"`
+	Repositories []Entry `yaml:"repositories"`
+}
+
+// Entry is a single repository with the checked out state
+type Entry struct {
+	Path   string `yaml:"path"`             // relative to the root directory
+	Url    string `yaml:"url"`              // origin remote
+	Branch string `yaml:"branch,omitempty"` // empty for a detached HEAD
+	Commit string `yaml:"commit,omitempty"` // empty for repositories without commits
+	Bare   bool   `yaml:"bare,omitempty"`
+}
+
+// Marshal sorts the entries by path, so lockfiles of the same state are identical
+func Marshal(entries []Entry) ([]byte, error) {
+	sorted := append([]Entry(nil), entries...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Path < sorted[j].Path
+	})
+	return yaml.Marshal(Lockfile{Version: Version, Repositories: sorted})
+}
+
+// Parse reads the lockfile, the paths have to stay within the root directory
+func Parse(content []byte) (*Lockfile, error) {
+	var result Lockfile
+	if err := yaml.UnmarshalStrict(content, &result); err != nil {
+		return nil, err
+	}
+	if result.Version != Version {
+		return nil, fmt.Errorf("unsupported lockfile version %d (expected %d)", result.Version, Version)
+	}
+	paths := map[string]bool{}
+	for i, entry := range result.Repositories {
+		if entry.Url == "" {
+			return nil, fmt.Errorf("missing url for entry %d", i+1)
+		}
+		cleaned := path.Clean(entry.Path)
+		if entry.Path == "" || cleaned == "." || path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+			return nil, fmt.Errorf("invalid path %q for entry %d, it has to be relative to the root directory", entry.Path, i+1)
+		}
+		if paths[cleaned] {
+			return nil, fmt.Errorf("duplicate path %s", cleaned)
+		}
+		paths[cleaned] = true
+		result.Repositories[i].Path = cleaned
+	}
+	return &result, nil
+}
@@ -0,0 +1,81 @@
+package lockfile
+
+import (
+	"testing"
+)
+
+type parseCase struct {
+	content string
+	paths   []string
+	failing bool
+}
+
+var parseCases = []parseCase{
+	{
+		content: "version: 1\nrepositories:\n  - path: acme/service/\n    url: git@gitlab.com:acme/service.git\n    branch: main\n    commit: 3f2a\n  - path: tools\n    url: https://github.com/acme/tools.git",
+		paths:   []string{"acme/service", "tools"},
+	},
+	{
+		content: "version: 2\nrepositories: []",
+		failing: true,
+	},
+	{
+		content: "version: 1\nrepositories:\n  - path: ../service\n    url: git@gitlab.com:acme/service.git",
+		failing: true,
+	},
+	{
+		content: "version: 1\nrepositories:\n  - path: /tmp/service\n    url: git@gitlab.com:acme/service.git",
+		failing: true,
+	},
+	{
+		content: "version: 1\nrepositories:\n  - path: service\n",
+		failing: true,
+	},
+	{
+		content: "version: 1\nrepositories:\n  - path: service\n    url: a\n  - path: ./service\n    url: b",
+		failing: true,
+	},
+	{
+		content: "version: 1\nrepos: []",
+		failing: true,
+	},
+}
+
+func TestParse(t *testing.T) {
+	for _, c := range parseCases {
+		result, err := Parse([]byte(c.content))
+		if c.failing {
+			if err == nil {
+				t.Errorf("Expected error for %q", c.content)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %s", c.content, err)
+			continue
+		}
+		if len(result.Repositories) != len(c.paths) {
+			t.Errorf("Expected %d entries, got %d", len(c.paths), len(result.Repositories))
+			continue
+		}
+		for i, entry := range result.Repositories {
+			if entry.Path != c.paths[i] {
+				t.Errorf("Expected path %s, got %s", c.paths[i], entry.Path)
+			}
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	content, err := Marshal([]Entry{{Path: "b", Url: "u2", Branch: "main", Commit: "c2"}, {Path: "a", Url: "u1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := Parse(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Repositories[0].Path != "a" || result.Repositories[1].Commit != "c2" {
+		t.Errorf("Unexpected roundtrip result: %+v", result.Repositories)
+	}
+}
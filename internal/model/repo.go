@@ -23,6 +23,8 @@ type RepoMeta struct {
 type RepoDir struct {
 	RepoMeta
 	Path string // The absolute path to the repository
+	Host string // The host of the hoster the origin remote points to, empty if unknown
+	Bare bool   // The repository has no working tree, eg. a mirror
 }
 
 type RepoRemote struct {
@@ -39,9 +41,9 @@ func (rd RepoDir) PathDirName() string {
 	return fiRepo.Name()
 }
 
-func MakeRepoDir(pathRepository string, hosterHost string) (*RepoDir, error) {
+func MakeRepoDir(pathRepository string, hosterHosts []string) (*RepoDir, error) {
 	result := &RepoDir{Path: pathRepository}
-	result.RemotePath = DetermineRemotePath(pathRepository, hosterHost)
+	result.Host, result.RemotePath = DetermineRemote(pathRepository, hosterHosts)
 	result.Name = result.PathDirName()
 
 	if util.ExistsFile(result.RepoYamlFilename()) {
@@ -70,6 +72,12 @@ func determineName(remotePath string) string {
 }
 
 func DetermineRemotePath(pathRepository string, hosterHost string) string {
+	_, result := DetermineRemote(pathRepository, []string{hosterHost})
+	return result
+}
+
+// DetermineRemote returns the first of the given hosts the origin remote points to, and the parsed remote path
+func DetermineRemote(pathRepository string, hosterHosts []string) (string, string) {
 	bufferOut := new(bytes.Buffer)
 	bufferErr := new(bytes.Buffer)
 	cmdGo := exec.Command("git", "remote", "-v")
@@ -80,28 +88,31 @@ func DetermineRemotePath(pathRepository string, hosterHost string) string {
 	if err != nil {
 		say.Error("git remote failed for %s: %s", pathRepository, err)
 		say.Error("%s", bufferErr.String())
-		return ""
+		return "", ""
 	}
 	lines := strings.Split(strings.ReplaceAll(bufferOut.String(), "\r\n", "\n"), "\n")
 
-	var result string
-	for _, line := range lines {
-		result = ParseRemotePath(line, hosterHost)
-		if len(result) > 0 {
-			break
+	for _, hosterHost := range hosterHosts {
+		for _, line := range lines {
+			result := ParseRemotePath(line, hosterHost)
+			if len(result) > 0 {
+				return hosterHost, result
+			}
 		}
 	}
-	return result
+	return "", ""
 }
 
 // TODO distinguish remote url notations, improve this approach
 func ParseRemotePath(path string, hosterHost string) string {
 	var result string
-	re, _ := regexp.Compile(`^origin[\t ]+((https|ssh):\/\/.*@?|git@)` + hosterHost + `[\/:]([a-zA-Z0-9_\/.-]+)[\t ]+.fetch.$`)
+	// the host follows the scheme or the user directly, so hosts with the same suffix (eg. git.acme.com) don't match
+	host := regexp.QuoteMeta(hosterHost)
+	re, _ := regexp.Compile(`^origin[\t ]+((https|ssh):\/\/(?:[^\/@\s]+@)?` + host + `(:[0-9]+)?\/|git@` + host + `[\/:])([a-zA-Z0-9_\/.~-]+)[\t ]+.fetch.$`)
 	matches := re.MatchString(path)
 	say.Verbose("Checking remote: %s, matches: %v", path, matches)
 	if matches {
-		result = re.FindStringSubmatch(path)[3]
+		result = re.FindStringSubmatch(path)[4]
 		result = strings.TrimSuffix(result, ".git") // greedy regex has issues in golang, workaround
 	}
 	return result
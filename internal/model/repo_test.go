@@ -33,6 +33,32 @@ var matchCases = []matchCase{
 		input: "origin	git@" + dummyHost + ":group/infrastructure/project.git (fetch)",
 		expected: "group/infrastructure/project",
 	},
+	{
+		input: "origin	ssh://git@" + dummyHost + ":7999/PROJ/some-repo.git (fetch)",
+		expected: "PROJ/some-repo",
+	},
+	{
+		input: "origin	ssh://git@" + dummyHost + "/~user/some-repo.git (fetch)",
+		expected: "~user/some-repo",
+	},
+}
+
+// remotes of other hosts, which only share a suffix or look alike
+var otherHostCases = []string{
+	"origin	https://git." + dummyHost + "/group/x.git (fetch)",
+	"origin	ssh://git@git." + dummyHost + ":7999/PROJ/x.git (fetch)",
+	"origin	git@git." + dummyHost + ":group/x.git (fetch)",
+	"origin	https://not" + dummyHost + "/group/x.git (fetch)",
+	"origin	https://blabla-com/group/x.git (fetch)",
+	"origin	https://" + dummyHost + ".evil.org/group/x.git (fetch)",
+}
+
+func TestMatchesOtherHosts(t *testing.T) {
+	for _, input := range otherHostCases {
+		if got := ParseRemotePath(input, dummyHost); got != "" {
+			t.Errorf("got %s, wanted no match for %s", got, input)
+		}
+	}
 }
 
 func TestMatches(t *testing.T) {
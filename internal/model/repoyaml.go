@@ -36,6 +36,31 @@ type Gitlab struct {
 	SharedRunnersEnabled                      *bool `yaml:"shared_runners_enabled"`
 }
 
+type Github struct {
+	HasWiki             *bool `yaml:"has_wiki"`
+	HasIssues           *bool `yaml:"has_issues"`
+	HasProjects         *bool `yaml:"has_projects"`
+	AllowForking        *bool `yaml:"allow_forking"`
+	AllowMergeCommit    *bool `yaml:"allow_merge_commit"`
+	AllowSquashMerge    *bool `yaml:"allow_squash_merge"`
+	AllowRebaseMerge    *bool `yaml:"allow_rebase_merge"`
+	DeleteBranchOnMerge *bool `yaml:"delete_branch_on_merge"`
+}
+
+type Gitea struct {
+	HasWiki                       *bool   `yaml:"has_wiki"`
+	HasIssues                     *bool   `yaml:"has_issues"`
+	HasProjects                   *bool   `yaml:"has_projects"`
+	HasPullRequests               *bool   `yaml:"has_pull_requests"`
+	AllowMerge                    *bool   `yaml:"allow_merge_commits"`
+	AllowRebase                   *bool   `yaml:"allow_rebase"`
+	AllowRebaseMerge              *bool   `yaml:"allow_rebase_explicit"`
+	AllowSquash                   *bool   `yaml:"allow_squash_merge"`
+	AllowFastForwardOnlyMerge     *bool   `yaml:"allow_fast_forward_only_merge"`
+	DefaultMergeStyle             *string `yaml:"default_merge_style"`
+	DefaultDeleteBranchAfterMerge *bool   `yaml:"default_delete_branch_after_merge"`
+}
+
 // Repo bla
 type RepoYaml struct {
 	Name        string            `yaml:"name"`
@@ -47,7 +72,8 @@ type RepoYaml struct {
 	Annotations map[string]string `yaml:"annotations"`
 	Contacts    []string          `yaml:"contacts"`
 	Gitlab      Gitlab            `yaml:"gitlab"`
-	//TODO Github
+	Github      Github            `yaml:"github"`
+	Gitea       Gitea             `yaml:"gitea"`
 }
 
 func (r *RepoYaml) ReadFromString(content string) error {
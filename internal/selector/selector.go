@@ -0,0 +1,306 @@
+package selector
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"repo/internal/hoster"
+	"repo/internal/model"
+)
+
+// Candidate is a repository the selector is evaluated against, either listed by the hoster or found locally
+type Candidate struct {
+	hoster.HosterRepository                 // listed attributes, for local repositories at least the path and topics
+	Starred                 bool            // the repository is starred by the user
+	RepoYaml                *model.RepoYaml // nil if the repository has no (valid) repo.yaml or it is unknown
+}
+
+// Keys with a value, eg. "topic:library"
+var valueKeys = []string{"topic", "path", "lang", "type", "org", "annotation", "contact", "visibility"}
+
+// Keys without a value, eg. "starred"
+var flagKeys = []string{"starred", "archived", "fork"}
+
+// ManifestKeys require the repo.yaml of the repositories
+var ManifestKeys = []string{"lang", "type", "org", "annotation", "contact"}
+
+// ListingKeys require the attributes listed by the hoster
+var ListingKeys = []string{"visibility", "starred", "archived", "fork"}
+
+type node interface {
+	matches(c Candidate) bool
+}
+
+type and struct{ left, right node }
+
+func (n and) matches(c Candidate) bool { return n.left.matches(c) && n.right.matches(c) }
+
+type or struct{ left, right node }
+
+func (n or) matches(c Candidate) bool { return n.left.matches(c) || n.right.matches(c) }
+
+type not struct{ operand node }
+
+func (n not) matches(c Candidate) bool { return !n.operand.matches(c) }
+
+type term struct {
+	key     string
+	value   string
+	entry   string         // value of the entry for "org" and "annotation", the key is in value then
+	pattern *regexp.Regexp // for "path"
+}
+
+func (t term) matches(c Candidate) bool {
+	switch t.key {
+	case "topic":
+		return slices.Contains(c.Topics, t.value)
+	case "path":
+		return t.pattern.MatchString(c.PathWithNamespace)
+	case "visibility":
+		return strings.EqualFold(c.Visibility, t.value)
+	case "starred":
+		return c.Starred
+	case "archived":
+		return c.Archived
+	case "fork":
+		return c.Fork
+	}
+	if c.RepoYaml == nil {
+		return false
+	}
+	switch t.key {
+	case "lang":
+		return slices.ContainsFunc(c.RepoYaml.Languages, func(lang string) bool { return strings.EqualFold(lang, t.value) })
+	case "type":
+		return strings.EqualFold(c.RepoYaml.Type, t.value)
+	case "org":
+		value, exists := c.RepoYaml.Org[t.value]
+		return exists && value == t.entry
+	case "annotation":
+		value, exists := c.RepoYaml.Annotations[t.value]
+		return exists && value == t.entry
+	case "contact":
+		return slices.Contains(c.RepoYaml.Contacts, t.value)
+	}
+	return false
+}
+
+// Selector is a parsed boolean expression selecting repositories
+type Selector struct {
+	expression string
+	root       node
+	keys       []string
+}
+
+// Matches evaluates the expression for the repository
+func (s *Selector) Matches(c Candidate) bool {
+	return s.root.matches(c)
+}
+
+// Uses is true, if one of the keys is part of the expression
+func (s *Selector) Uses(keys ...string) bool {
+	return slices.ContainsFunc(keys, func(key string) bool { return slices.Contains(s.keys, key) })
+}
+
+func (s *Selector) String() string {
+	return s.expression
+}
+
+// Parse creates a selector from an expression like `topic:library && !path:^legacy/ || (lang:go && starred)`.
+// The negation "!" binds strongest, followed by "&&" and "||". Values containing whitespace, parentheses or
+// operators can be quoted, eg. path:`^(api|web)/`.
+func Parse(expression string) (*Selector, error) {
+	p := &parser{input: expression}
+	if err := p.tokenize(); err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %s", expression, err)
+	}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("invalid expression %q: empty", expression)
+	}
+	root, err := p.parseOr()
+	if err == nil && p.position < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q at position %d", p.tokens[p.position].text, p.tokens[p.position].offset+1)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %s", expression, err)
+	}
+	return &Selector{expression: expression, root: root, keys: p.keys}, nil
+}
+
+type token struct {
+	text     string // operator or the raw term
+	offset   int
+	operator bool
+}
+
+type parser struct {
+	input    string
+	tokens   []token
+	position int
+	keys     []string
+}
+
+func (p *parser) tokenize() error {
+	for i := 0; i < len(p.input); {
+		rest := p.input[i:]
+		switch {
+		case unicode.IsSpace(rune(rest[0])):
+			i++
+		case strings.HasPrefix(rest, "&&"), strings.HasPrefix(rest, "||"):
+			p.tokens = append(p.tokens, token{text: rest[:2], offset: i, operator: true})
+			i += 2
+		case rest[0] == '!' || rest[0] == '(' || rest[0] == ')':
+			p.tokens = append(p.tokens, token{text: rest[:1], offset: i, operator: true})
+			i++
+		default:
+			length, err := termLength(rest)
+			if err != nil {
+				return fmt.Errorf("%s at position %d", err, i+1)
+			}
+			p.tokens = append(p.tokens, token{text: rest[:length], offset: i})
+			i += length
+		}
+	}
+	return nil
+}
+
+// a term ends with whitespace, a parenthesis or an operator, unless they are quoted
+func termLength(s string) (int, error) {
+	i := 0
+	for i < len(s) {
+		rest := s[i:]
+		if unicode.IsSpace(rune(rest[0])) || rest[0] == '(' || rest[0] == ')' || strings.HasPrefix(rest, "&&") || strings.HasPrefix(rest, "||") {
+			break
+		}
+		if rest[0] == '"' || rest[0] == '`' {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return 0, fmt.Errorf("unterminated quote")
+			}
+			i += len(quoted)
+			continue
+		}
+		i++
+	}
+	return i, nil
+}
+
+func (p *parser) peek() string {
+	if p.position < len(p.tokens) && p.tokens[p.position].operator {
+		return p.tokens[p.position].text
+	}
+	return ""
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.peek() == "||" {
+		p.position++
+		var right node
+		right, err = p.parseAnd()
+		left = or{left, right}
+	}
+	return left, err
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	for err == nil && p.peek() == "&&" {
+		p.position++
+		var right node
+		right, err = p.parseUnary()
+		left = and{left, right}
+	}
+	return left, err
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.position >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end")
+	}
+	current := p.tokens[p.position]
+	p.position++
+	if !current.operator {
+		return p.parseTerm(current)
+	}
+	switch current.text {
+	case "!":
+		operand, err := p.parseUnary()
+		return not{operand}, err
+	case "(":
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis for position %d", current.offset+1)
+		}
+		p.position++
+		return inner, nil
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", current.text, current.offset+1)
+}
+
+func (p *parser) parseTerm(t token) (node, error) {
+	key, raw, hasValue := strings.Cut(t.text, ":")
+	key = strings.ToLower(key)
+	if !slices.Contains(valueKeys, key) && !slices.Contains(flagKeys, key) {
+		return nil, fmt.Errorf("unknown key %q at position %d (available: %s)", key, t.offset+1, strings.Join(append(slices.Clone(valueKeys), flagKeys...), ", "))
+	}
+	if slices.Contains(flagKeys, key) {
+		if hasValue {
+			return nil, fmt.Errorf("%s has no value at position %d", key, t.offset+1)
+		}
+		p.keys = append(p.keys, key)
+		return term{key: key}, nil
+	}
+
+	value, err := unquote(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for %s at position %d: %s", key, t.offset+1, err)
+	}
+	if value == "" {
+		return nil, fmt.Errorf("missing value for %s at position %d", key, t.offset+1)
+	}
+	result := term{key: key, value: value}
+	switch key {
+	case "path":
+		if result.pattern, err = regexp.Compile(value); err != nil {
+			return nil, fmt.Errorf("invalid pattern for path at position %d: %s", t.offset+1, err)
+		}
+	case "org", "annotation":
+		var found bool
+		if result.value, result.entry, found = strings.Cut(value, "="); !found {
+			return nil, fmt.Errorf("%s expects key=value at position %d", key, t.offset+1)
+		}
+	}
+	p.keys = append(p.keys, key)
+	return result, nil
+}
+
+// removes the quotes of quoted parts within the value, eg. path:"^my group/"
+func unquote(raw string) (string, error) {
+	var result strings.Builder
+	for len(raw) > 0 {
+		if raw[0] != '"' && raw[0] != '`' {
+			result.WriteByte(raw[0])
+			raw = raw[1:]
+			continue
+		}
+		quoted, err := strconv.QuotedPrefix(raw)
+		if err != nil {
+			return "", err
+		}
+		unquoted, err := strconv.Unquote(quoted)
+		if err != nil {
+			return "", err
+		}
+		result.WriteString(unquoted)
+		raw = raw[len(quoted):]
+	}
+	return result.String(), nil
+}
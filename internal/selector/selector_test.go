@@ -0,0 +1,119 @@
+package selector
+
+import (
+	"strings"
+	"testing"
+
+	"repo/internal/hoster"
+	"repo/internal/model"
+)
+
+var library = Candidate{
+	HosterRepository: hoster.HosterRepository{PathWithNamespace: "acme/core/library", Topics: []string{"library"}, Visibility: "internal"},
+	RepoYaml: &model.RepoYaml{
+		Type:        "library",
+		Languages:   []string{"Go"},
+		Org:         map[string]string{"squad": "user"},
+		Annotations: map[string]string{"acme.corp/access": "iam"},
+		Contacts:    []string{"galan"},
+	},
+}
+
+var legacy = Candidate{
+	HosterRepository: hoster.HosterRepository{PathWithNamespace: "legacy/library", Topics: []string{"library"}, Archived: true, Fork: true},
+}
+
+var starred = Candidate{
+	HosterRepository: hoster.HosterRepository{PathWithNamespace: "legacy/tool"},
+	Starred:          true,
+	RepoYaml:         &model.RepoYaml{Languages: []string{"go"}},
+}
+
+type matchCase struct {
+	expression string
+	candidate  Candidate
+	expected   bool
+}
+
+var matchCases = []matchCase{
+	{"topic:library", library, true},
+	{"topic:service", library, false},
+	{"path:^acme/", library, true},
+	{"!path:^acme/", library, false},
+	{"topic:library && !path:^legacy/ || (lang:go && starred)", library, true},
+	{"topic:library && !path:^legacy/ || (lang:go && starred)", legacy, false},
+	{"topic:library && !path:^legacy/ || (lang:go && starred)", starred, true},
+	{"topic:library && (!path:^legacy/ || lang:go && starred)", starred, false},
+	{"lang:go", legacy, false},
+	{"type:library && org:squad=user", library, true},
+	{"org:squad=other || org:tribe=user", library, false},
+	{"annotation:acme.corp/access=iam && contact:galan", library, true},
+	{"contact:someone", library, false},
+	{"visibility:internal", library, true},
+	{"visibility:internal", legacy, false},
+	{"archived && fork", legacy, true},
+	{"!archived", legacy, false},
+	{"!!archived", legacy, true},
+	{"path:`^(acme|legacy)/core`", library, true},
+	{`path:"^legacy/ "`, legacy, false},
+	{"path:`^legacy/(tool)$`", starred, true},
+	{"path:acme||path:legacy", legacy, true},
+	{"(((starred)))", starred, true},
+}
+
+func TestMatches(t *testing.T) {
+	for _, test := range matchCases {
+		selector, err := Parse(test.expression)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", test.expression, err)
+			continue
+		}
+		if got := selector.Matches(test.candidate); got != test.expected {
+			t.Errorf("got %t, wanted %t for %q on %s", got, test.expected, test.expression, test.candidate.PathWithNamespace)
+		}
+	}
+}
+
+var invalidExpressions = map[string]string{
+	"":                       "empty",
+	"topic:":                 "missing value",
+	"owner:galan":            "unknown key",
+	"starred:true":           "has no value",
+	"org:squad":              "expects key=value",
+	"path:[":                 "invalid pattern",
+	"topic:a &&":             "unexpected end",
+	"topic:a || || topic:b":  "unexpected \"||\"",
+	"(topic:a":               "missing closing parenthesis",
+	"topic:a)":               "unexpected \")\"",
+	"topic:a topic:b":        "unexpected \"topic:b\"",
+	`path:"^legacy`:          "unterminated quote",
+	"topic:a && !":           "unexpected end",
+	"topic:library && ()":    "unexpected \")\"",
+	"(topic:a || topic:b))":  "unexpected \")\"",
+	"topic:a & topic:b":      "unexpected \"&\"",
+	"path:^legacy/(tool)$":   "unexpected \"(\"",
+	"topic:a &&& topic:b":    "unknown key \"&\"",
+	"visibility:private && ": "unexpected end",
+}
+
+func TestParseInvalid(t *testing.T) {
+	for expression, expected := range invalidExpressions {
+		_, err := Parse(expression)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error containing %q for %q, got %v", expected, expression, err)
+		}
+	}
+}
+
+func TestUses(t *testing.T) {
+	selector, err := Parse("topic:library || (lang:go && !starred)")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !selector.Uses(ManifestKeys...) || !selector.Uses(ListingKeys...) {
+		t.Errorf("expected manifest and listing keys to be used")
+	}
+	if selector.Uses("path", "contact") {
+		t.Errorf("expected path and contact not to be used")
+	}
+}
@@ -0,0 +1,23 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseDuration extends time.ParseDuration with days and weeks, eg. "90d" or "2w"
+func ParseDuration(value string) (time.Duration, error) {
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	for suffix, unit := range units {
+		if count, found := strings.CutSuffix(value, suffix); found {
+			number, err := strconv.Atoi(count)
+			if err != nil || number < 0 {
+				return 0, fmt.Errorf("invalid duration %q", value)
+			}
+			return time.Duration(number) * unit, nil
+		}
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,26 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	cases := map[string]time.Duration{
+		"90d": 90 * 24 * time.Hour,
+		"2w":  14 * 24 * time.Hour,
+		"36h": 36 * time.Hour,
+		"0d":  0,
+	}
+	for value, expected := range cases {
+		result, err := ParseDuration(value)
+		if err != nil || result != expected {
+			t.Errorf("Expected %s for %q, got %s (%v)", expected, value, result, err)
+		}
+	}
+	for _, value := range []string{"d", "-1d", "1.5d", "90 days", ""} {
+		if _, err := ParseDuration(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}